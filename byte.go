@@ -2,7 +2,9 @@ package wtff
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 type Byte float64
@@ -20,22 +22,34 @@ func (b Byte) String() string {
 	return fmt.Sprintf("%.1f%s", b*1024, units[i-1])
 }
 
-// TODO: finish and move to zstd (it's enough for our purpose here).
+// UnmarshalText parses a size, with an optional K, M, G, T, or P suffix (e.g.
+// "700M" or "1.5G"). The suffixes are powers of 1024, and may be followed by
+// "B" or "iB" ("700MB", "700MiB").
+//
+// TODO: move to zstd (it's enough for our purpose here).
 func (b *Byte) UnmarshalText(in []byte) error {
-	s := string(in)
-	switch {
-	// case strings.HasSuffix(s, "B"):
-	// case strings.HasSuffix(s, "K"):
-	// case strings.HasSuffix(s, "M"):
-	// case strings.HasSuffix(s, "G"):
-	// case strings.HasSuffix(s, "T"):
-	// case strings.HasSuffix(s, "P"):
+	s := strings.TrimSpace(string(in))
+	if strings.HasPrefix(s, "-") {
+		return fmt.Errorf("invalid size: %q: negative", in)
+	}
+
+	num, suffix := s, ""
+	if i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' }); i > -1 {
+		num, suffix = s[:i], strings.TrimSpace(s[i:])
+	}
+	mult := 1.0
+	switch u := strings.ToUpper(suffix); {
+	case u == "" || u == "B":
+	case strings.Contains("KMGTP", u[:1]) && (len(u) == 1 || u[1:] == "B" || u[1:] == "IB"):
+		mult = math.Pow(1024, float64(strings.Index("KMGTP", u[:1])+1))
 	default:
-		sz, err := strconv.ParseUint(s, 10, 64)
-		if err != nil {
-			return err
-		}
-		*b = Byte(sz)
+		return fmt.Errorf("invalid size: %q: unknown suffix %q", in, suffix)
+	}
+
+	sz, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsInf(sz*mult, 0) {
+		return fmt.Errorf("invalid size: %q", in)
 	}
+	*b = Byte(sz * mult)
 	return nil
 }
@@ -0,0 +1,96 @@
+package wtff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestByteUnmarshalText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Byte
+	}{
+		{"0", 0},
+		{"12345", 12345},
+		{"5B", 5},
+		{"1k", 1024},
+		{"1K", 1024},
+		{"700M", 700 * 1024 * 1024},
+		{"2G", 2 * 1024 * 1024 * 1024},
+		{"1T", 1024 * 1024 * 1024 * 1024},
+		{"1P", 1024 * 1024 * 1024 * 1024 * 1024},
+		{"1KB", 1024},
+		{"1KiB", 1024},
+		{"700MB", 700 * 1024 * 1024},
+		{"700MiB", 700 * 1024 * 1024},
+		{"700 mib", 700 * 1024 * 1024},
+		{"1.5G", 1.5 * 1024 * 1024 * 1024},
+		{".5K", 512},
+		{"  10M  ", 10 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var have Byte
+			if err := have.UnmarshalText([]byte(tt.in)); err != nil {
+				t.Fatal(err)
+			}
+			if have != tt.want {
+				t.Errorf("\nhave: %f\nwant: %f", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestByteUnmarshalTextError(t *testing.T) {
+	tests := []struct {
+		in, wantErr string
+	}{
+		{"", "invalid size"},
+		{"K", "invalid size"},
+		{".", "invalid size"},
+		{"1.2.3", "invalid size"},
+		{"inf", "unknown suffix"},
+		{"Inf", "unknown suffix"},
+		{"NaN", "unknown suffix"},
+		{"1e3", "unknown suffix"},
+		{"0x1p3", "unknown suffix"},
+		{"-5", "negative"},
+		{"-5M", "negative"},
+		{"5iB", "unknown suffix"},
+		{"5X", "unknown suffix"},
+		{"5KX", "unknown suffix"},
+		{"5MM", "unknown suffix"},
+		{"5 M B", "unknown suffix"},
+		{strings.Repeat("9", 400), "invalid size"},
+		{strings.Repeat("9", 300) + "P", "invalid size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var b Byte
+			err := b.UnmarshalText([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestByteString(t *testing.T) {
+	tests := []struct {
+		in   Byte
+		want string
+	}{
+		{0, "0.0B"},
+		{1023, "1023.0B"},
+		{1024, "1.0K"},
+		{1536, "1.5K"},
+		{700 * 1024 * 1024, "700.0M"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if have := tt.in.String(); have != tt.want {
+				t.Errorf("\nhave: %s\nwant: %s", have, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package wtff
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file has conversions of chapters to and from various formats. The
+// parsers sort the chapters and set the End of all chapters without one to the
+// start of the next chapter, and to 0 for the last chapter, which
+// Editor.Write() and WriteMeta() set to the end of the file.
+
+type (
+	mkvChapters struct {
+		XMLName  xml.Name     `xml:"Chapters"`
+		Editions []mkvEdition `xml:"EditionEntry"`
+	}
+	mkvEdition struct {
+		Atoms []mkvAtom `xml:"ChapterAtom"`
+	}
+	mkvAtom struct {
+		Start   string       `xml:"ChapterTimeStart"`
+		End     string       `xml:"ChapterTimeEnd,omitempty"`
+		Display []mkvDisplay `xml:"ChapterDisplay"`
+	}
+	mkvDisplay struct {
+		String   string `xml:"ChapterString"`
+		Language string `xml:"ChapterLanguage,omitempty"`
+	}
+)
+
+// MatroskaChapters gets the chapters in the Matroska chapter XML format, as
+// used by mkvmerge and mkvextract.
+func (m Meta) MatroskaChapters() string {
+	c := mkvChapters{Editions: make([]mkvEdition, 1)}
+	for _, ch := range m.Chapters {
+		a := mkvAtom{Start: fmtClock(ch.StartTime(), 9)}
+		if ch.End > ch.Start {
+			a.End = fmtClock(ch.EndTime(), 9)
+		}
+		a.Display = append(a.Display, mkvDisplay{String: ch.Title, Language: "und"})
+		c.Editions[0].Atoms = append(c.Editions[0].Atoms, a)
+	}
+
+	out, err := xml.MarshalIndent(c, "", "  ")
+	if err != nil {
+		panic(err) // Never happens.
+	}
+	return xml.Header + `<!DOCTYPE Chapters SYSTEM "matroskachapters.dtd">` + "\n" + string(out) + "\n"
+}
+
+// ParseMatroskaChapters parses chapters in the Matroska chapter XML format.
+// Only the first edition is used, and nested chapters are ignored.
+func ParseMatroskaChapters(input string) (Meta, error) {
+	var (
+		m Meta
+		c mkvChapters
+	)
+	err := xml.Unmarshal([]byte(input), &c)
+	if err != nil {
+		return m, fmt.Errorf("wtff.ParseMatroskaChapters: %w", err)
+	}
+	if len(c.Editions) == 0 {
+		return m, nil
+	}
+	for i, a := range c.Editions[0].Atoms {
+		ch := MetaChapter{Timebase: [2]int64{1, 1000}}
+		ch.Start, err = parseChapterTime(strings.TrimSpace(a.Start))
+		if err != nil {
+			return m, fmt.Errorf("wtff.ParseMatroskaChapters: chapter %d: %w", i+1, err)
+		}
+		if a.End != "" {
+			ch.End, err = parseChapterTime(strings.TrimSpace(a.End))
+			if err != nil {
+				return m, fmt.Errorf("wtff.ParseMatroskaChapters: chapter %d: %w", i+1, err)
+			}
+		}
+		if len(a.Display) > 0 {
+			ch.Title = a.Display[0].String
+		}
+		m.Chapters = append(m.Chapters, ch)
+	}
+	m.Chapters = importedChapters(m.Chapters)
+	return m, nil
+}
+
+// OGMChapters gets the chapters in the OGM format:
+//
+//	CHAPTER01=00:00:00.000
+//	CHAPTER01NAME=Intro
+func (m Meta) OGMChapters() string {
+	b := new(strings.Builder)
+	for i, c := range m.Chapters {
+		fmt.Fprintf(b, "CHAPTER%02d=%s\n", i+1, fmtClock(c.StartTime(), 3))
+		fmt.Fprintf(b, "CHAPTER%02dNAME=%s\n", i+1, c.Title)
+	}
+	return b.String()
+}
+
+var reOGM = regexp.MustCompile(`^CHAPTER(\d+)(NAME)?=(.*)$`)
+
+// ParseOGMChapters parses chapters in the OGM format.
+func ParseOGMChapters(input string) (Meta, error) {
+	var (
+		m     Meta
+		index = make(map[string]int)
+	)
+	for i, line := range strings.Split(input, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		match := reOGM.FindStringSubmatch(line)
+		if match == nil {
+			return m, fmt.Errorf("wtff.ParseOGMChapters: line %d: %q", i+1, line)
+		}
+		n, ok := index[match[1]]
+		if !ok {
+			n = len(m.Chapters)
+			index[match[1]] = n
+			m.Chapters = append(m.Chapters, MetaChapter{Timebase: [2]int64{1, 1000}})
+		}
+		if match[2] != "" {
+			m.Chapters[n].Title = match[3]
+			continue
+		}
+		var err error
+		m.Chapters[n].Start, err = parseChapterTime(match[3])
+		if err != nil {
+			return m, fmt.Errorf("wtff.ParseOGMChapters: line %d: %w", i+1, err)
+		}
+	}
+	m.Chapters = importedChapters(m.Chapters)
+	return m, nil
+}
+
+// YouTubeChapters gets the chapters as timestamps for a YouTube video
+// description:
+//
+//	00:00 Intro
+//	01:23 The Good Stuff
+//
+// YouTube only supports whole seconds, and the first chapter must start at
+// 00:00.
+func (m Meta) YouTubeChapters() string {
+	hours := len(m.Chapters) > 0 && m.Chapters[len(m.Chapters)-1].StartTime() >= time.Hour
+	b := new(strings.Builder)
+	for _, c := range m.Chapters {
+		fmt.Fprintf(b, "%s %s\n", fmtChapterTime(c.StartTime().Truncate(time.Second), hours), c.Title)
+	}
+	return b.String()
+}
+
+var reYouTube = regexp.MustCompile(`^[\s\-*•]*[(\[]?((?:\d+:)?\d+:\d\d)[)\]]?\s*(?:[-–—:|]\s*)?(.*)$`)
+
+// ParseYouTubeChapters parses chapters from a YouTube video description; all
+// lines that don't start with a timestamp are ignored.
+func ParseYouTubeChapters(input string) (Meta, error) {
+	var m Meta
+	for i, line := range strings.Split(input, "\n") {
+		match := reYouTube.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		start, err := parseChapterTime(match[1])
+		if err != nil {
+			return m, fmt.Errorf("wtff.ParseYouTubeChapters: line %d: %w", i+1, err)
+		}
+		m.Chapters = append(m.Chapters, MetaChapter{
+			Timebase: [2]int64{1, 1000},
+			Start:    start,
+			Title:    strings.TrimSpace(match[2]),
+		})
+	}
+	m.Chapters = importedChapters(m.Chapters)
+	return m, nil
+}
+
+type (
+	podcastChapters struct {
+		Version  string           `json:"version"`
+		Title    string           `json:"title,omitempty"`
+		Author   string           `json:"author,omitempty"`
+		Chapters []podcastChapter `json:"chapters"`
+	}
+	podcastChapter struct {
+		StartTime float64 `json:"startTime"`
+		EndTime   float64 `json:"endTime,omitempty"`
+		Title     string  `json:"title,omitempty"`
+	}
+)
+
+// PodcastChapters gets the chapters in the Podcasting 2.0 JSON chapters format.
+//
+// https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/examples/chapters/jsonChapters.md
+func (m Meta) PodcastChapters() string {
+	p := podcastChapters{Version: "1.2.0", Title: m.Title, Author: m.Artist, Chapters: []podcastChapter{}}
+	for _, c := range m.Chapters {
+		pc := podcastChapter{StartTime: c.StartTime().Seconds(), Title: c.Title}
+		if c.End > c.Start {
+			pc.EndTime = c.EndTime().Seconds()
+		}
+		p.Chapters = append(p.Chapters, pc)
+	}
+	b := new(strings.Builder)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(p)
+	if err != nil {
+		panic(err) // Never happens.
+	}
+	return b.String()
+}
+
+// ParsePodcastChapters parses chapters in the Podcasting 2.0 JSON chapters
+// format. The title and author are set as the title and artist.
+func ParsePodcastChapters(input string) (Meta, error) {
+	var p podcastChapters
+	err := json.Unmarshal([]byte(input), &p)
+	if err != nil {
+		return Meta{}, fmt.Errorf("wtff.ParsePodcastChapters: %w", err)
+	}
+	m := Meta{Title: p.Title, Artist: p.Author}
+	for _, c := range p.Chapters {
+		m.Chapters = append(m.Chapters, MetaChapter{
+			Timebase: [2]int64{1, 1000},
+			Start:    int64(c.StartTime*1000 + .5),
+			End:      int64(c.EndTime*1000 + .5),
+			Title:    c.Title,
+		})
+	}
+	m.Chapters = importedChapters(m.Chapters)
+	return m, nil
+}
+
+// importedChapters sorts the chapters by start time, and sets the End of all
+// but the last chapter if it's not set.
+func importedChapters(chapters []MetaChapter) []MetaChapter {
+	slices.SortStableFunc(chapters, func(a, b MetaChapter) int { return cmp.Compare(a.Start, b.Start) })
+	return chapterEnds(chapters, 0)
+}
+
+// fmtClock formats d as "HH:MM:SS" with prec fractional digits.
+func fmtClock(d time.Duration, prec int) string {
+	t := fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	if prec > 0 {
+		f := strconv.FormatInt(int64(d%time.Second), 10)
+		f = strings.Repeat("0", 9-len(f)) + f
+		t += "." + f[:prec]
+	}
+	return t
+}
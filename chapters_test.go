@@ -0,0 +1,168 @@
+package wtff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChapterFormats(t *testing.T) {
+	ch := func(start, end int64, title string) MetaChapter {
+		return MetaChapter{Timebase: [2]int64{1, 1000}, Start: start, End: end, Title: title}
+	}
+	tests := []struct {
+		name  string
+		parse func(string) (Meta, error)
+		write func(Meta) string
+		in    string
+		want  Meta
+	}{
+		{"mkv", ParseMatroskaChapters, Meta.MatroskaChapters, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE Chapters SYSTEM "matroskachapters.dtd">
+<Chapters>
+  <EditionEntry>
+    <ChapterAtom>
+      <ChapterTimeStart>00:01:23.500000000</ChapterTimeStart>
+      <ChapterDisplay><ChapterString>Two &amp; more</ChapterString></ChapterDisplay>
+    </ChapterAtom>
+    <ChapterAtom>
+      <ChapterTimeStart> 00:00:00.000000000 </ChapterTimeStart>
+      <ChapterTimeEnd>00:01:00.000000000</ChapterTimeEnd>
+      <ChapterDisplay>
+        <ChapterString>One</ChapterString>
+        <ChapterLanguage>eng</ChapterLanguage>
+      </ChapterDisplay>
+      <ChapterDisplay><ChapterString>Een</ChapterString></ChapterDisplay>
+    </ChapterAtom>
+    <ChapterAtom>
+      <ChapterTimeStart>01:00:00</ChapterTimeStart>
+    </ChapterAtom>
+  </EditionEntry>
+  <EditionEntry>
+    <ChapterAtom><ChapterTimeStart>00:00:05</ChapterTimeStart></ChapterAtom>
+  </EditionEntry>
+</Chapters>`, Meta{Chapters: []MetaChapter{
+			ch(0, 60_000, "One"),
+			ch(83_500, 3_600_000, "Two & more"),
+			ch(3_600_000, 0, ""),
+		}}},
+
+		{"ogm", ParseOGMChapters, Meta.OGMChapters,
+			"CHAPTER01=00:00:00.000\r\nCHAPTER01NAME=Intro\r\n\r\nCHAPTER02=00:01:23.456\nCHAPTER02NAME=A = B\nCHAPTER03NAME=Named first\nCHAPTER03=01:02:03.000\n",
+			Meta{Chapters: []MetaChapter{
+				ch(0, 83_456, "Intro"),
+				ch(83_456, 3_723_000, "A = B"),
+				ch(3_723_000, 0, "Named first"),
+			}}},
+
+		{"youtube", ParseYouTubeChapters, Meta.YouTubeChapters, `Some description.
+
+0:00 Intro
+1:23 - The Good Stuff
+  • [02:05] Third: part
+Not a chapter 3:00
+1:02:03 Fourth`,
+			Meta{Chapters: []MetaChapter{
+				ch(0, 83_000, "Intro"),
+				ch(83_000, 125_000, "The Good Stuff"),
+				ch(125_000, 3_723_000, "Third: part"),
+				ch(3_723_000, 0, "Fourth"),
+			}}},
+
+		{"podcast", ParsePodcastChapters, Meta.PodcastChapters, `{
+  "version": "1.2.0",
+  "title": "Episode 1",
+  "author": "Someone",
+  "chapters": [
+    {"startTime": 0, "title": "Intro"},
+    {"startTime": 83.5, "endTime": 90, "title": "<Two>"},
+    {"startTime": 120.25}
+  ]
+}`, Meta{Title: "Episode 1", Artist: "Someone", Chapters: []MetaChapter{
+			ch(0, 83_500, "Intro"),
+			ch(83_500, 90_000, "<Two>"),
+			ch(120_250, 0, ""),
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := tt.parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(normalMeta(have), normalMeta(tt.want)) {
+				t.Errorf("\nhave: %#v\nwant: %#v", have, tt.want)
+			}
+
+			out := tt.write(have)
+			have, err = tt.parse(out)
+			if err != nil {
+				t.Fatalf("round-trip: %s\n%s", err, out)
+			}
+			if !reflect.DeepEqual(normalMeta(have), normalMeta(tt.want)) {
+				t.Errorf("round-trip:\nhave: %#v\nwant: %#v\n%s", have, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestChapterFormatsWrite(t *testing.T) {
+	m := Meta{Chapters: []MetaChapter{
+		{Timebase: [2]int64{1, 1000}, Start: 0, End: 1500, Title: "One"},
+		{Timebase: [2]int64{1, 1000}, Start: 1500, Title: "Two"},
+	}}
+	tests := []struct {
+		name string
+		have string
+		want string
+	}{
+		{"ogm", m.OGMChapters(), "CHAPTER01=00:00:00.000\nCHAPTER01NAME=One\nCHAPTER02=00:00:01.500\nCHAPTER02NAME=Two\n"},
+		{"youtube", m.YouTubeChapters(), "00:00 One\n00:01 Two\n"},
+		{"mkv", m.MatroskaChapters(), `<ChapterTimeStart>00:00:01.500000000</ChapterTimeStart>`},
+		{"podcast", m.PodcastChapters(), `"startTime": 1.5,`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(tt.have, tt.want) {
+				t.Errorf("\nhave:\n%s\nwant:\n%s", tt.have, tt.want)
+			}
+		})
+	}
+}
+
+func TestChapterFormatsError(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) (Meta, error)
+		in      string
+		wantErr string
+	}{
+		{"mkv", ParseMatroskaChapters, "<Chapters><EditionEntry>", "wtff.ParseMatroskaChapters: XML syntax error"},
+		{"mkv", ParseMatroskaChapters, "not xml", "wtff.ParseMatroskaChapters: EOF"},
+		{"mkv", ParseMatroskaChapters,
+			"<Chapters><EditionEntry><ChapterAtom><ChapterTimeStart>xx</ChapterTimeStart></ChapterAtom></EditionEntry></Chapters>",
+			"chapter 1: \"xx\": not in the format"},
+		{"mkv", ParseMatroskaChapters,
+			"<Chapters><EditionEntry><ChapterAtom><ChapterTimeStart>00:00:01</ChapterTimeStart><ChapterTimeEnd>1</ChapterTimeEnd></ChapterAtom></EditionEntry></Chapters>",
+			"chapter 1: \"1\": not in the format"},
+
+		{"ogm", ParseOGMChapters, "CHAPTER01=00:00:00.000\nfoo\n", `line 2: "foo"`},
+		{"ogm", ParseOGMChapters, "CHAPTER01=yesterday\n", `line 1: "yesterday"`},
+		{"ogm", ParseOGMChapters, "CHAPTER01=00:-1:00\n", "line 1:"},
+
+		{"youtube", ParseYouTubeChapters, "0:00 Intro\n99999999999999999999:00 Overflow\n", "line 2:"},
+
+		{"podcast", ParsePodcastChapters, `{"chapters": [`, "wtff.ParsePodcastChapters: unexpected end of JSON input"},
+		{"podcast", ParsePodcastChapters, `{"chapters": "x"}`, "wtff.ParsePodcastChapters: json: cannot unmarshal"},
+		{"podcast", ParsePodcastChapters, `{"chapters": [{"startTime": "1"}]}`, "wtff.ParsePodcastChapters: json: cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
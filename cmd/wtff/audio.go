@@ -7,24 +7,25 @@ import (
 	"zgo.at/zli"
 )
 
-func cmdAudio(f zli.Flags, cmd string) error {
+func cmdAudio(ctx context.Context, f zli.Flags, cmd string) error {
 	switch cmd {
 	case "add":
 		var (
 			lang  = f.String("", "l", "lang")
 			title = f.String("", "t", "title")
+			disp  = f.StringList(nil, "d", "disposition")
 		)
 		zli.F(f.Parse())
 		if len(f.Args) != 2 && len(f.Args) != 3 && len(f.Args) != 4 {
-			zli.Fatalf("usage: wtff audio add [-l lang] [-t title] [media] [audio-file]")
+			zli.Fatalf("usage: wtff audio add [-l lang] [-t title] [-d disposition] [media] [audio-file]")
 		}
-		return cmdAudioAdd(f.Args[0], f.Args[1], lang.String(), title.String())
+		return cmdAudioAdd(ctx, f.Args[0], f.Args[1], lang.String(), title.String(), disp.StringsSplit(",")...)
 	case "rm":
 		zli.F(f.Parse())
 		if len(f.Args) != 2 {
 			zli.Fatalf("usage: wtff audio rm [media] [stream]")
 		}
-		return cmdAudioRm(f.Args[0], f.Args[1])
+		return cmdAudioRm(ctx, f.Args[0], f.Args[1])
 	case "save":
 		var (
 			output = f.String("", "o", "output")
@@ -33,7 +34,7 @@ func cmdAudio(f zli.Flags, cmd string) error {
 		if len(f.Args) != 3 {
 			zli.Fatalf("usage: wtff audio save [-o output] [media] [stream]")
 		}
-		return cmdAudioSave(f.Args[0], f.Args[1], output.String())
+		return cmdAudioSave(ctx, f.Args[0], f.Args[1], output.String())
 	case "replace":
 		zli.F(f.Parse())
 		return nil // TODO
@@ -41,14 +42,14 @@ func cmdAudio(f zli.Flags, cmd string) error {
 	panic("unreachable")
 }
 
-func cmdAudioAdd(input, audioFile, lang, title string) error {
-	return wtff.AudioAdd(context.Background(), input, audioFile, lang, title)
+func cmdAudioAdd(ctx context.Context, input, audioFile, lang, title string, disp ...string) error {
+	return wtff.AudioAdd(ctx, input, audioFile, lang, title, disp...)
 }
 
-func cmdAudioRm(input, stream string) error {
-	return wtff.AudioRm(context.Background(), input, stream)
+func cmdAudioRm(ctx context.Context, input, stream string) error {
+	return wtff.AudioRm(ctx, input, stream)
 }
 
-func cmdAudioSave(input, stream, output string) error {
-	return wtff.AudioSave(context.Background(), input, stream, output)
+func cmdAudioSave(ctx context.Context, input, stream, output string) error {
+	return wtff.AudioSave(ctx, input, stream, output)
 }
@@ -3,45 +3,115 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"zgo.at/wtff"
+	"zgo.at/zstd/zfilepath"
 )
 
-func cmdCat(force bool, output string, input ...string) error {
-	if !force {
-		formats := make([]string, 0, len(input))
-		for _, f := range input {
-			info, err := wtff.Probe(context.Background(), f)
-			if err != nil {
-				return err
-			}
+func cmdCat(ctx context.Context, force bool, output, order, title string, opt wtff.CatOptions, input ...string) error {
+	input, err := catInputs(input)
+	if err != nil {
+		return err
+	}
+	if order != "" {
+		if err := wtff.SortInputs(ctx, order, input); err != nil {
+			return err
+		}
+	}
 
-			f := make([]string, 0, 4)
-			for _, s := range info.Streams {
-				if s.CodecType != "subtitle" && s.CodecType != "data" {
-					f = append(f, fmt.Sprintf("%s %s %dx%d", s.CodecType, s.CodecName, s.Width, s.Height))
-				}
-			}
-			formats = append(formats, strings.Join(f, "\n"))
+	if title != "" {
+		if _, err := expandTemplate(title, catVars(1, input[0], wtff.Meta{}), nil); err != nil {
+			return err
 		}
-		same := true
-		for i := range formats {
-			if i > 0 && formats[i] != formats[i-1] {
-				same = false
-				break
-			}
+		opt.ChapterTitle = func(n int, input string, m wtff.Meta) string {
+			t, _ := expandTemplate(title, catVars(n, input, m), nil)
+			return t
 		}
-		if !same {
-			fmt.Println("Formats not identical:")
-			for i := range formats {
-				fmt.Println("  " + filepath.Base(input[i]) + "\n" + "    " + strings.ReplaceAll(formats[i], "\n", "\n    "))
-			}
+	}
+	if !force && !opt.Normalize {
+		report, err := wtff.CheckConcatWith(ctx, opt, input...)
+		if err != nil {
+			return err
+		}
+		indent := "  " + strings.ReplaceAll(strings.TrimSuffix(report.String(), "\n"), "\n", "\n  ")
+		if !report.OK() {
+			fmt.Println("Formats not compatible:")
+			fmt.Println(indent)
 			fmt.Println("\nEdit files to make the streams compatible (if possible), or use -f to force.")
 			return nil
 		}
+		if len(report.Problems) > 0 {
+			fmt.Fprintln(os.Stderr, "Formats not identical, but should work:")
+			fmt.Fprintln(os.Stderr, indent)
+		}
 	}
 
-	return wtff.Cat(context.Background(), output, input...)
+	return wtff.CatWith(ctx, output, opt, input...)
+}
+
+// catInputs expands playlists and glob patterns in the input list.
+func catInputs(args []string) ([]string, error) {
+	input := make([]string, 0, len(args))
+	for _, a := range args {
+		switch _, ext := zfilepath.SplitExt(a); strings.ToLower(ext) {
+		case "m3u", "m3u8", "txt", "lst":
+			files, err := wtff.ReadPlaylist(a)
+			if err != nil {
+				return nil, err
+			}
+			input = append(input, files...)
+			continue
+		}
+
+		// Patterns that are quoted or not expanded by the shell (e.g. on
+		// Windows).
+		if _, err := os.Stat(a); err == nil || !strings.ContainsAny(a, "*?[") {
+			input = append(input, a)
+			continue
+		}
+		files, err := filepath.Glob(a)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", a, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files matching %q", a)
+		}
+		input = append(input, files...)
+	}
+	return input, nil
+}
+
+// catVars gets the template variables for the chapter title of the nth input.
+func catVars(n int, input string, m wtff.Meta) map[string]string {
+	name, _ := zfilepath.SplitExt(filepath.Base(input))
+	vars := map[string]string{
+		"n":           strconv.Itoa(n),
+		"filename":    filepath.Base(input),
+		"name":        name,
+		"meta.title":  m.Title,
+		"meta.artist": m.Artist,
+		"meta.date":   m.Date,
+	}
+	for k, v := range m.Other {
+		vars["meta."+k] = v
+	}
+	return vars
+}
+
+func parseCatTarget(size, fps string, rate int) (wtff.CatTarget, error) {
+	t := wtff.CatTarget{FrameRate: fps, SampleRate: rate}
+	if size != "" {
+		w, h, ok := strings.Cut(size, "x")
+		var err1, err2 error
+		t.Width, err1 = strconv.Atoi(w)
+		t.Height, err2 = strconv.Atoi(h)
+		if !ok || err1 != nil || err2 != nil || t.Width <= 0 || t.Height <= 0 {
+			return t, fmt.Errorf("invalid size: %q; must be as WxH, e.g. 1920x1080", size)
+		}
+	}
+	return t, nil
 }
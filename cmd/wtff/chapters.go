@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zgo.at/wtff"
+	"zgo.at/zli"
+)
+
+func cmdChapters(ctx context.Context, f zli.Flags, cmd string) error {
+	var (
+		format = f.String("", "f", "format")
+		output = f.String("", "o", "output")
+	)
+	zli.F(f.Parse())
+	switch {
+	case cmd == "export" && len(f.Args) != 1:
+		zli.Fatalf("usage: wtff chapters export [-f format] [-o output] [input]")
+	case cmd == "import" && len(f.Args) != 2:
+		zli.Fatalf("usage: wtff chapters import [-f format] [-o output] [chapter-file] [input]")
+	}
+
+	if cmd == "import" {
+		chFile, input := f.Args[0], f.Args[1]
+		ch, err := importChapters(format.String(), chFile)
+		if err != nil {
+			return err
+		}
+		m, err := wtff.ReadMeta(ctx, input)
+		if err != nil {
+			return err
+		}
+		m.Streams, m.Chapters = nil, ch.Chapters
+
+		out := output.String()
+		if out == "" {
+			out = input
+		}
+		return wtff.WriteMeta(ctx, m, input, out)
+	}
+
+	m, err := wtff.ReadMeta(ctx, f.Args[0])
+	if err != nil {
+		return err
+	}
+	fmtName, err := chapterFormat(format.String(), output.String())
+	if err != nil {
+		return err
+	}
+	var out string
+	switch fmtName {
+	case "cue":
+		out = m.Cue(f.Args[0])
+	case "mkv":
+		out = m.MatroskaChapters()
+	case "ogm":
+		out = m.OGMChapters()
+	case "youtube":
+		out = m.YouTubeChapters()
+	case "podcast":
+		out = m.PodcastChapters()
+	case "ffmetadata":
+		m.Streams = nil
+		out = m.String()
+	}
+
+	if output.String() == "" {
+		fmt.Print(out)
+		return nil
+	}
+	return wtff.WriteFile(ctx, output.String(), out)
+}
+
+func importChapters(format, file string) (wtff.Meta, error) {
+	fmtName, err := chapterFormat(format, file)
+	if err != nil {
+		return wtff.Meta{}, err
+	}
+	d, err := os.ReadFile(file)
+	if err != nil {
+		return wtff.Meta{}, err
+	}
+	data := string(d)
+
+	switch fmtName {
+	case "cue":
+		return wtff.ParseCue(data)
+	case "mkv":
+		return wtff.ParseMatroskaChapters(data)
+	case "ogm":
+		return wtff.ParseOGMChapters(data)
+	case "youtube":
+		return wtff.ParseYouTubeChapters(data)
+	case "podcast":
+		return wtff.ParsePodcastChapters(data)
+	default: // ffmetadata
+		return wtff.ParseMeta(data)
+	}
+}
+
+// chapterFormat gets the chapter format from the -f flag, or guesses it from
+// the file extension.
+func chapterFormat(format, file string) (string, error) {
+	switch strings.ToLower(format) {
+	case "cue", "mkv", "ogm", "youtube", "podcast", "ffmetadata":
+		return strings.ToLower(format), nil
+	case "matroska", "xml":
+		return "mkv", nil
+	case "json":
+		return "podcast", nil
+	case "":
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".cue":
+			return "cue", nil
+		case ".xml":
+			return "mkv", nil
+		case ".json":
+			return "podcast", nil
+		}
+		return "", fmt.Errorf("need to set the format with -f")
+	default:
+		return "", fmt.Errorf("unknown format: %q", format)
+	}
+}
+
+// metaFromCue gets the metadata of input with the tags and chapters from the
+// CUE sheet.
+func metaFromCue(ctx context.Context, input, cueFile string) (wtff.Meta, error) {
+	c, err := os.ReadFile(cueFile)
+	if err != nil {
+		return wtff.Meta{}, err
+	}
+	cue, err := wtff.ParseCue(string(c))
+	if err != nil {
+		return wtff.Meta{}, err
+	}
+	m, err := wtff.ReadMeta(ctx, input)
+	if err != nil {
+		return wtff.Meta{}, err
+	}
+
+	m.Streams = nil
+	m.Chapters = cue.Chapters
+	if cue.Title != "" {
+		m.Title = cue.Title
+	}
+	if cue.Artist != "" {
+		m.Artist = cue.Artist
+	}
+	if cue.Date != "" {
+		m.Date = cue.Date
+	}
+	for k, v := range cue.Other {
+		if m.Other == nil {
+			m.Other = make(map[string]string)
+		}
+		m.Other[k] = v
+	}
+	return m, nil
+}
@@ -4,66 +4,70 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
-	"time"
 
 	"zgo.at/wtff"
+	"zgo.at/zstd/zfilepath"
 )
 
-func cmdCut(input, output, verb, startStr, endStr string) error {
-	start, err := parseTime(startStr)
+func cmdCut(ctx context.Context, input, output, srt string, remove, accurate bool, args []string) error {
+	info, err := wtff.Probe(ctx, input)
 	if err != nil {
-		return fmt.Errorf("invalid: %q: %s", startStr, err)
+		return err
 	}
-	stop, err := parseTime(endStr)
-	if err != nil {
-		return fmt.Errorf("invalid: %q: %s", endStr, err)
-	}
-	switch strings.ToLower(verb) {
-	default:
-		return fmt.Errorf("invalid: %q", verb)
-	case "to":
-		stop = wtff.Time{stop.Duration - start.Duration}
-	case "for":
-		// Do nothing.
-	}
-	return wtff.Cut(context.Background(), input, output, start, stop)
-}
 
-func parseTime(s string) (wtff.Time, error) {
-	sp := strings.Split(s, ":")
-	if len(sp) < 2 {
-		return wtff.Time{}, errors.New("not enough :")
-	} else if len(sp) > 3 {
-		return wtff.Time{}, errors.New("too many :")
-	}
-	var sub time.Duration
-	if i := strings.IndexByte(sp[len(sp)-1], '.'); i > -1 {
-		var x string
-		sp[len(sp)-1], x, _ = strings.Cut(sp[len(sp)-1], ".")
-		var err error
-		sub, err = time.ParseDuration("0." + x + "s")
+	var ranges []wtff.Range
+	for _, r := range strings.Split(strings.Join(args, " "), ",") {
+		f := strings.Fields(r)
+		if len(f) != 3 {
+			return fmt.Errorf("invalid range: %q", strings.TrimSpace(r))
+		}
+		start, stop, err := parseRange(info, f[0], f[1], f[2])
 		if err != nil {
-			return wtff.Time{}, err
+			return err
 		}
+		ranges = append(ranges, wtff.Range{Start: start, End: stop})
 	}
 
-	a, err := strconv.Atoi(sp[0])
-	if err != nil {
-		return wtff.Time{}, err
+	if accurate && (len(ranges) > 1 || remove) {
+		return errors.New("-accurate only works with a single range")
 	}
-	b, err := strconv.Atoi(sp[1])
-	if err != nil {
-		return wtff.Time{}, err
+
+	switch {
+	case len(ranges) == 1 && !remove:
+		stop := wtff.Time{Duration: ranges[0].End.Duration - ranges[0].Start.Duration}
+		if accurate {
+			err = wtff.CutAccurate(ctx, input, output, ranges[0].Start, stop)
+		} else {
+			err = wtff.Cut(ctx, input, output, ranges[0].Start, stop)
+		}
+	default:
+		err = wtff.CutRanges(ctx, input, output, remove, ranges...)
 	}
-	if len(sp) == 2 {
-		return wtff.Time{sub + time.Duration(a)*time.Minute + time.Duration(b)*time.Second}, nil
+	if err != nil || srt == "" {
+		return err
 	}
+	base, _ := zfilepath.SplitExt(output)
+	return wtff.CutSubs(ctx, srt, base+".srt", remove, ranges...)
+}
 
-	c, err := strconv.Atoi(sp[2])
+// parseRange parses "start to stop" or "start for duration".
+func parseRange(info wtff.ProbeFile, startStr, verb, endStr string) (wtff.Time, wtff.Time, error) {
+	start, err := wtff.ParseTime(startStr, info)
 	if err != nil {
-		return wtff.Time{}, err
+		return start, start, err
+	}
+	switch strings.ToLower(verb) {
+	default:
+		return start, start, fmt.Errorf("invalid: %q", verb)
+	case "to":
+		stop, err := wtff.ParseTime(endStr, info)
+		return start, stop, err
+	case "for":
+		if strings.HasPrefix(endStr, "-") {
+			return start, start, fmt.Errorf("invalid: %q: duration can't be negative", endStr)
+		}
+		d, err := wtff.ParseTime(endStr, info)
+		return start, wtff.Time{Duration: start.Duration + d.Duration}, err
 	}
-	return wtff.Time{sub + time.Duration(a)*time.Hour + time.Duration(b)*time.Minute + time.Duration(c)*time.Second}, nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"zgo.at/wtff"
+)
+
+func cmdEdit(ctx context.Context, input, output, tomlFile string,
+	addSub, rmSub, addAudio, rmAudio, keep, drop, order, setDefault []string,
+) error {
+	if output == "" {
+		output = input
+	}
+
+	e := wtff.Edit(input)
+	for _, s := range rmSub {
+		e.RemoveSub(s)
+	}
+	for _, s := range rmAudio {
+		e.RemoveAudio(s)
+	}
+	for _, s := range drop {
+		e.DropStreams(s)
+	}
+	for _, s := range keep {
+		e.KeepStreams(s)
+	}
+	if len(order) > 0 {
+		e.OrderStreams(order...)
+	}
+	for _, s := range setDefault {
+		e.SetDefault(s)
+	}
+	for _, s := range addSub {
+		f, lang, _ := splitFileOpts(s)
+		e.AddSub(f, lang)
+	}
+	for _, s := range addAudio {
+		f, opt, _ := splitFileOpts(s)
+		lang, title, _ := strings.Cut(opt, ":")
+		e.AddAudio(f, lang, title)
+	}
+	if tomlFile != "" {
+		n, err := os.ReadFile(tomlFile)
+		if err != nil {
+			return err
+		}
+		info, err := wtff.Probe(ctx, input)
+		if err != nil {
+			return err
+		}
+		m, err := wtff.ParseMetaFromTOML(string(n), info.Format.Duration.Duration)
+		if err != nil {
+			return err
+		}
+		e.SetMeta(m)
+	}
+	return e.Write(ctx, output)
+}
+
+// splitFileOpts splits "file:opts" on the first ":" after the last "/".
+func splitFileOpts(s string) (string, string, bool) {
+	i := strings.LastIndexByte(s, '/') + 1
+	f, opts, ok := strings.Cut(s[i:], ":")
+	return s[:i] + f, opts, ok
+}
@@ -6,20 +6,21 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"zgo.at/wtff"
 	"zgo.at/zli"
 	"zgo.at/zstd/zmap"
 )
 
-func cmdInfo(meta, jsonFlag bool, files ...string) error {
+func cmdInfo(ctx context.Context, meta, jsonFlag bool, files ...string) error {
 	if jsonFlag {
 		fmt.Print("[")
 		for i, file := range files {
 			if i > 0 {
 				fmt.Println(",")
 			}
-			info, err := wtff.Probe(context.Background(), file)
+			info, err := wtff.Probe(ctx, file)
 			if err != nil {
 				return err
 			}
@@ -36,7 +37,7 @@ func cmdInfo(meta, jsonFlag bool, files ...string) error {
 	multi := len(files) > 1
 
 	for i, file := range files {
-		info, err := wtff.Probe(context.Background(), file)
+		info, err := wtff.Probe(ctx, file)
 		if !multi && err != nil { /// For multi print the errors below path and continue
 			return err
 		}
@@ -55,7 +56,7 @@ func cmdInfo(meta, jsonFlag bool, files ...string) error {
 			fmt.Println(info)
 		}
 		if meta {
-			m, err := wtff.ReadMeta(context.Background(), file)
+			m, err := wtff.ReadMeta(ctx, file)
 			if !multi && err != nil {
 				return err
 			}
@@ -93,3 +94,67 @@ func prMeta(b *strings.Builder, w int, k, v string) {
 		fmt.Fprintln(b, v)
 	}
 }
+
+func cmdKeyframes(ctx context.Context, at string, files ...string) error {
+	for i, file := range files {
+		var atTime wtff.Time
+		if at != "" {
+			info, err := wtff.Probe(ctx, file)
+			if err != nil {
+				return err
+			}
+			atTime, err = wtff.ParseTime(at, info)
+			if err != nil {
+				return err
+			}
+		}
+		gops, err := wtff.Keyframes(ctx, file, -1)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		zli.Colorf(file, zli.Bold)
+		fmt.Printf(" (%d keyframes)\n", len(gops))
+		if len(gops) == 0 {
+			continue
+		}
+
+		if at != "" {
+			before, after := gops.Nearest(atTime.Duration)
+			if before == atTime.Duration {
+				fmt.Printf("    %s is on a keyframe\n", atTime)
+				continue
+			}
+			if before > -1 {
+				fmt.Printf("    before  %-12s  -%.3fs\n", wtff.Time{Duration: before}, (atTime.Duration - before).Seconds())
+			}
+			if after > -1 {
+				fmt.Printf("    after   %-12s  +%.3fs\n", wtff.Time{Duration: after}, (after - atTime.Duration).Seconds())
+			}
+			continue
+		}
+
+		var (
+			total              time.Duration
+			frames             int
+			minGOP, maxGOP     = gops[0].Duration, gops[0].Duration
+			minFrame, maxFrame = gops[0].Frames, gops[0].Frames
+		)
+		for _, g := range gops {
+			total += g.Duration
+			frames += g.Frames
+			minGOP, maxGOP = min(minGOP, g.Duration), max(maxGOP, g.Duration)
+			minFrame, maxFrame = min(minFrame, g.Frames), max(maxFrame, g.Frames)
+		}
+		fmt.Printf("    GOP length: avg %.3fs (%d frames), min %.3fs (%d frames), max %.3fs (%d frames)\n\n",
+			(total / time.Duration(len(gops))).Seconds(), frames/len(gops),
+			minGOP.Seconds(), minFrame, maxGOP.Seconds(), maxFrame)
+		fmt.Printf("    %-12s  %8s  %6s  %8s\n", "start", "length", "frames", "size")
+		for _, g := range gops {
+			fmt.Printf("    %-12s  %7.3fs  %6d  %8s\n", wtff.Time{Duration: g.Start}, g.Duration.Seconds(), g.Frames, g.Size)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -13,20 +14,38 @@ var usageBrief = `
 wtff is a frontend for ffmpeg. https://github.com/arp242/wtff
 
 Commands:
-    info         [-m] [-j] [file] [file...]
-    meta         [-w] [-s] [-t toml-file] [file]
+    info         [-m] [-j] [-k] [-at time] [file] [file...]
+    meta         [-w] [-s] [-t toml-file] [-cue cue-file] [file]
     mb           [-artist artist] [-album album] [-r release-id] [file]
-    cat          [-f] [-o output] [input...]
-    cut          [-o output] [input] [start] [verb] [stop]
-    sub add      [-l lang] [input] [sub-file]
+    cat          [-f] [-o output] [-sort order] [-merge-chapters]
+                 [-nest-chapters] [-title template] [-subs] [-normalize]
+                 [-size WxH] [-fps rate] [-rate rate] [input...]
+    cut          [-o output] [-a] [-remove] [-srt file] [input] [start] [verb]
+                 [stop], ...
+    split        [-cue cue-file] [-chapters] [-every length] [-size size]
+                 [-o template] [input]
+    chapters export [-f format] [-o output] [input]
+    chapters import [-f format] [-o output] [chapter-file] [input]
+    edit         [-o output] [-add-sub file] [-rm-sub stream] [-add-audio file]
+                 [-rm-audio stream] [-keep stream] [-drop stream]
+                 [-order stream] [-default stream] [-t toml-file] [input]
+    streams keep [-o output] [input] [stream]
+    streams drop [-o output] [input] [stream]
+    streams order [-o output] [input] [stream...]
+    streams set-default [-o output] [input] [stream]
+    streams set-forced [-o output] [input] [stream]
+    streams unset-forced [-o output] [input] [stream]
+    streams disposition [-o output] [input] [stream] [disposition]
+    sub add      [-l lang] [-d disposition] [input] [sub-file]
     sub rm       [input] [stream]
     sub save     [input] [stream] [output]
     sub print    [input] [stream]
-    audio add    [-l lang] [-t title] [input] [audio-file]
+    audio add    [-l lang] [-t title] [-d disposition] [input] [audio-file]
     audio rm     [input] [stream]
     audio save   [-o output] [input] [stream]
 
-Use the -v flag with any command to print the ffmpeg invocations to stderr.
+Use the -v flag with any command to print the ffmpeg invocations to stderr,
+or -n to print the commands that would be run without running them.
 
 Use "help" or "-h" for full help.
 `[1:]
@@ -36,18 +55,36 @@ wtff is a frontend for ffmpeg. https://github.com/arp242/wtff
 
 Use the -v flag with any command to print the ffmpeg invocations to stderr.
 
+Use the -n flag with any command for a dry-run: print the commands that would be
+run to stdout as a shell script, without modifying any files. Files are still
+read.
+
+A progress bar is shown on stderr for long-running operations if stderr is a
+terminal.
+
 Commands:
-    info [-m] [-j] [file] [file...]
+    info [-m] [-j] [-k] [-at time] [file] [file...]
             Show list of streams and chapters for all given files. This is
             similar to ffprobe, but more compat and excludes most metadata.
 
             Flags:
                 -m, -meta      Display more metadata.
                 -j, -json      Output as JSON.
+                -k, -keyframes Show the keyframes of the first video stream
+                               instead, with the length, number of frames,
+                               and size of every GOP. This reads the entire
+                               file.
+                -at            With -keyframes, show only the keyframes
+                               before and after this time, for finding a cut
+                               point that doesn't need re-encoding.
 
-    meta [-w] [-s] [-t toml-file] [file]
+    meta [-w] [-s] [-t toml-file] [-cue cue-file] [file]
             Edit metadata as a TOML file with $EDITOR. Keys can be deleted to
-            remove that data.
+            remove that data. The [[streams]] entries set the language, title,
+            and tags of every stream; streams are matched by index, and
+            removing an entry leaves that stream's metadata alone.
+            Chapter times are as [HH:]MM:SS[.fff]; the end is optional and
+            defaults to the start of the next chapter or the end of the file.
             Writes to temp file in the file's directory, and overwrites the
             original on success.
 
@@ -59,6 +96,8 @@ Commands:
                                of populating from metadata from the input file.
                 -w, -write     Write without starting $EDITOR; only makes sense
                                if -t is set.
+                -cue           Set the artist, title, date, and chapters from
+                               a CUE sheet and write without starting $EDITOR.
 
     mb [-artist artist] [-album album] [-r release-id] [file]
              Load metadata from MusicBrainz and open $EDITOR (as with the meta
@@ -74,56 +113,274 @@ Commands:
              instead of searching. That's the ID in:
              https://musicbrainz.org/release/68395b54-0890-3d70-b031-8103824b073a
 
-    cat [-f] [-o output] [input...]
+    cat [-f] [-o output] [-sort order] [-merge-chapters] [-nest-chapters]
+        [-title template] [-subs] [-normalize] [-size WxH] [-fps rate]
+        [-rate rate] [input...]
             Cat all the input files to the output without recoding data.
             Filenames are added as chapters (if the format supports it).
 
+            Inputs ending in .m3u, .m3u8, .txt, or .lst are read as a playlist
+            with one file per line; relative paths are resolved against the
+            directory of the playlist. Glob patterns such as 'part*.mp3' are
+            expanded if they're not expanded by the shell.
+
+            The files are joined in the order they're given, unless -sort is
+            set:
+                natural        By filename, with numbers sorted by value, so
+                               that part2.mp3 comes before part10.mp3.
+                mtime          By modification time, oldest first.
+                meta-track     By the disc and track number tags.
+
+            For example to make an audiobook:
+                % wtff cat -sort natural -o book.mp3 'chapter*.mp3'
+
             The files must be compatible: same streams, with same encoding, with
-            same parameters (e.g. resolution). There are some basic checks for
-            this, but it's not guaranteed to be comprehensive. May give wonky
-            results if they're not identical
+            same parameters (e.g. resolution). The codec, profile, pixel
+            format, resolution, sample rate, and channels are checked, and
+            nothing is written if they differ. Differences in the aspect ratio,
+            frame rate, and time base are reported, but the files are still
+            joined. This is not guaranteed to be comprehensive, and may give
+            wonky results if the files are not identical.
 
             This can also be used to change the container format when used with
             just one input file; for example to change a AVI to MP4:
                 % wtff file.avi file.mp4
 
+            With -normalize the inputs that aren't compatible with the first
+            input are re-encoded to match it: video is scaled and padded to
+            the same resolution, and audio is resampled. Only the first video
+            and audio stream are kept. If the video of any input needs to be
+            re-encoded then the video of all inputs is. The target can be
+            changed with -size, -fps, and -rate, for example to join phone
+            clips in 1080p:
+                % wtff cat -normalize -size 1920x1080 -o out.mp4 *.mp4
+
+            The chapter title for every file can be set with -title; this is a
+            template with the variables {n}, {filename}, {name} (the filename
+            without extension), {meta.title}, {meta.artist}, {meta.date}, and
+            {meta.<tag>} for other tags. Numbers can be padded with {n:02}.
+
+            With -merge-chapters the chapters of the inputs are kept, and only
+            files without chapters get a chapter for the entire file. Add
+            -nest-chapters to prefix them with the file's chapter title:
+                % wtff cat -merge-chapters -nest-chapters -title '{meta.title}' \
+                      -o out.mkv part1.mkv part2.mkv
+
+            With -subs the text subtitles of all files are joined, also if only
+            some files have subtitles; other subtitles are dropped, and
+            subtitles aren't checked for compatibility.
+
             Flags:
                 -f, -force     Force operation, even if files look incompatible.
+                -sort          Sort the inputs: natural, mtime, or meta-track.
+                -merge-chapters
+                               Keep the chapters of the input files.
+                -nest-chapters Prefix merged chapters with the file's title.
+                -title         Template for the chapter titles.
+                -subs          Join subtitles of all files.
+                -normalize     Re-encode inputs that aren't compatible.
+                -size          Resolution to use with -normalize, as WxH.
+                -fps           Frame rate to use with -normalize, e.g. 30 or
+                               30000/1001.
+                -rate          Audio sample rate to use with -normalize.
 
-    cut [-o output] [input] [start] [verb] [stop]
+    cut [-o output] [-a] [-remove] [-srt file] [input] [start] [verb] [stop], ...
            Cut a pieces from a file:
 
                 00:01:33  to  00:01:40   Explicit start/stop times.
                 01:33.123 to  01:40.123  Sub-second, omitting hour
                 01:33.123 for 00:01:00   for 1 minute
+                93.5      for 1m30s      Seconds, or a Go duration
+                01:00     to  -00:30     Until 30 seconds before the end
+                f1200     to  f3600      Frame numbers
+                00:01:00:12 to 00:02:00:00
+                                         SMPTE timecode; use ";" as the
+                                         last separator for drop-frame
+
+           Times starting with "-" are relative to the end; use "--" before
+           the start time to prevent them from being seen as flags:
+
+                % wtff cut -o out.mkv in.mkv -- -05:00 to -00:30
+
+           Multiple ranges can be given separated by a comma, which are joined
+           in the output; chapters are adjusted to match:
+
+                % wtff cut -o out.mkv in.mkv 00:10 to 01:00, 05:00 to 07:30
+
+           Nothing is re-encoded, so video is cut on the keyframe before the
+           start time; a warning is printed if that's not the exact time.
+
+           Flags:
+               -a, -accurate  Cut on the exact time, by re-encoding only the
+                              video up to the first keyframe and after the
+                              last keyframe. Only works with one range,
+                              and with H.264, HEVC, VP8, VP9, and AV1.
+               -remove        Keep everything except the given ranges.
+               -srt           Also cut this SRT subtitle file, writing it
+                              next to the output with the .srt extension.
+
+    split [-cue cue-file] [-chapters] [-every length] [-size size]
+          [-o template] [input]
+           Split a file without re-encoding. With -cue or -chapters every file
+           gets the global tags, with the chapter title as the title and the
+           track number. With -every and -size video is cut on the last
+           keyframe before every cut point, so parts may be a bit shorter.
+
+           Flags:
+               -cue          Split on the tracks from this CUE sheet.
+               -chapters     Split on the chapters in the file.
+               -every        Split in parts of this length, e.g. 10:00.
+               -size         Split in parts of at most this size, e.g. 700M;
+                             K, M, G, and T suffixes are powers of 1024.
+               -o, -output   Filename template. Variables: {n} (part
+                             number), {name} (input filename without
+                             extension), and {ext}. With -cue and -chapters
+                             also {total}, {title}, {artist}, and {album}.
+                             Numbers can be padded with zeroes, e.g. {n:03}.
+                             Default: "{n:02} {title}.{ext}" with -cue and
+                             -chapters, "{name}-{n:02}.{ext}" otherwise.
+
+    chapters export [-f format] [-o output] [input]
+           Write the chapters in another format to stdout, or the file given
+           with -o. The format is guessed from the -o extension if -f isn't
+           given. Supported formats:
+
+               cue          CUE sheet (.cue).
+               mkv          Matroska chapter XML, as used by mkvmerge (.xml).
+               ogm          OGM chapters: "CHAPTER01=00:00:00.000".
+               youtube      Timestamps for a YouTube description: "00:00 Title".
+               podcast      Podcasting 2.0 JSON chapters (.json).
+               ffmetadata   ffmpeg metadata file.
+
+    chapters import [-f format] [-o output] [chapter-file] [input]
+           Replace the chapters in input with the chapters from chapter-file,
+           in one of the formats from "chapters export". The input file is
+           overwritten if -o is not given.
+
+    edit [-o output] [-add-sub file] [-rm-sub stream] [-add-audio file]
+         [-rm-audio stream] [-keep stream] [-drop stream] [-order stream]
+         [-default stream] [-t toml-file] [input]
+           Apply several edits at once, writing the file only once. This is
+           much faster than running the sub, audio, and meta commands one after
+           the other on large files. All flags can be given more than once.
+           The input file is overwritten if -o is not given.
+
+           Flags:
+               -o, -output       Write to this file, instead of overwriting
+                                 the input file.
+               -add-sub          Add subtitle, as file[:lang], e.g.
+                                 "en.srt:eng".
+               -rm-sub           Remove subtitle stream, as with "sub rm".
+               -add-audio        Add audio track, as file[:lang[:title]], e.g.
+                                 "commentary.mp3:eng:Commentary".
+               -rm-audio         Remove audio track, as with "audio rm".
+               -keep             Keep streams, as with "streams keep".
+               -drop             Remove streams, as with "streams drop".
+               -order            Put streams first, as with "streams order".
+               -default          Set default stream, as with
+                                 "streams set-default".
+               -t, -toml         Set metadata from the TOML file, in the same
+                                 format as the "meta" command.
+
+    streams keep [-o output] [input] [stream]
+           Keep only the streams matching the stream selector, for the stream
+           types the selector matches; streams of other types are always kept.
+           For example "a:eng,a:jpn" removes all audio tracks that aren't
+           English or Japanese, but keeps all video, subtitles, attachments
+           (e.g. fonts), chapters, and metadata.
+
+           The input file is overwritten if -o is not given.
+
+    streams drop [-o output] [input] [stream]
+           Remove all streams matching the stream selector; all other streams,
+           chapters, and metadata are kept.
+
+    streams order [-o output] [input] [stream...]
+           Put the streams matching the stream selectors first, in the order
+           given; all other streams are kept in their original order after
+           that. For example to put the Japanese audio before the English
+           audio:
+
+               % wtff streams order movie.mkv v a:jpn a:eng
+
+    streams set-default [-o output] [input] [stream]
+           Set the "default" disposition on the streams matching the stream
+           selector, and remove it from all other streams of the same type.
+           For example to make the Japanese audio and English subtitles the
+           default:
+
+               % wtff streams set-default movie.mkv a:jpn,s:eng
+
+    streams set-forced [-o output] [input] [stream]
+    streams unset-forced [-o output] [input] [stream]
+           Add or remove the "forced" disposition on the streams matching the
+           stream selector.
+
+    streams disposition [-o output] [input] [stream] [disposition]
+           Set the disposition on the streams matching the stream selector, in
+           the same format as ffmpeg's -disposition: flags joined with "+",
+           which replace the current dispositions (e.g. "default+forced"), a
+           "+" or "-" prefix to add or remove a flag (e.g. "+hearing_impaired"
+           or "-default"), or "0" to clear everything.
+
+           Flags: default, dub, original, comment, lyrics, karaoke, forced,
+           hearing_impaired, visual_impaired, clean_effects, attached_pic,
+           timed_thumbnails
 
-    sub add [-l lang] [input] [sub-file]
+    sub add [-l lang] [-d disposition] [input] [sub-file]
            Add a new subtitle from file; [lang] is optional and should be the
-           3-letter language code (e.g. eng).
+           3-letter language code (e.g. eng). Dispositions can be set with -d
+           as a comma-separated list (e.g. "default,forced").
 
     sub rm [input] [stream]
-           Remove subtitle from a file; the stream can either be a stream number
-           (as reported in 'wtff info') or language. Will remove all matching
-           subtitles when using a language name. Use the stream "ALL" to remove
-           all subtitles.
+           Remove all subtitles matching the stream selector from a file; see
+           "Stream selectors" below. Use the stream "ALL" to remove all
+           subtitles.
 
     sub save [-o output] [input] [stream]
-           Save subtitle to file.
+           Save subtitle to file; the stream selector must match exactly one
+           subtitle.
 
     sub print [input] [stream]
            Print subtitle to stdout.
 
-    audio add [-l lang] [-t title] [input] [audio-file]
-           Add a new audio track from audio-file.
+    audio add [-l lang] [-t title] [-d disposition] [input] [audio-file]
+           Add a new audio track from audio-file. Dispositions can be set with
+           -d as a comma-separated list (e.g. "comment").
 
     audio rm [input] [stream]
-           Remove audio track from a file; the stream can either be a stream
-           number (as reported in 'wtff info') or language. Will remove all
-           matching tracks when using a language name. Use the stream "ALL" to
-           remove all audio tracks.
+           Remove all audio tracks matching the stream selector from a file;
+           see "Stream selectors" below. Use the stream "ALL" to remove all
+           audio tracks.
 
     audio save [-o output] [input] [stream]
-           Save audio to file.
+           Save audio to file; the stream selector must match exactly one audio
+           track.
+
+Stream selectors:
+    Commands that accept a [stream] use a selector to match streams. This is a
+    comma-separated list of terms, and a stream is matched if any term matches.
+    A term is one or more conditions joined with "+", all of which must match.
+    Prefix a condition with "!" to negate it.
+
+        3                  Stream index 3, as reported by "wtff info".
+        eng                Language; streams without a language are "und".
+        ALL                All streams.
+        a                  All streams of this type: v (video), a (audio),
+                           s (subtitle), d (data), or t (attachment).
+        a:1                The second audio stream; counting starts at 0.
+        a:eng              All English audio streams.
+        a:!eng             All audio streams that are not English.
+        codec=dts          Codec name.
+        lang=eng           Language.
+        title=Commentary   Title, matched exactly.
+        title~commentary   Title contains the text, case-insensitive.
+        disposition=forced Disposition is set.
+        tag=value          Any other tag; also works with ~.
+
+    For example, to remove all non-English and commentary audio tracks:
+
+        % wtff audio rm movie.mkv 'a:!eng,title~commentary'
 `[1:]
 
 func main() {
@@ -131,6 +388,7 @@ func main() {
 	var (
 		helpFlag    = f.Bool(false, "h", "help")
 		verboseFlag = f.Bool(false, "v", "verbose")
+		dryRunFlag  = f.Bool(false, "n", "dry-run")
 	)
 	zli.F(f.Parse(zli.AllowUnknown()))
 	if helpFlag.Bool() {
@@ -140,32 +398,56 @@ func main() {
 	if verboseFlag.Bool() {
 		wtff.ShowFFCmd = true
 	}
-	cmd, err := f.ShiftCommand("help", "info", "meta", "mb", "cut", "cat", "subs", "audio")
+	cmd, err := f.ShiftCommand("help", "info", "meta", "mb", "cut", "split", "chapters", "cat", "edit", "streams", "subs", "audio")
 	if errors.Is(err, zli.ErrCommandNoneGiven{}) {
 		fmt.Print(usageBrief)
 		return
 	}
 	zli.F(err)
 
+	var (
+		ctx  = context.Background()
+		plan *wtff.Plan
+	)
+	ctx = wtff.WithWarning(ctx, func(msg string) { zli.Errorf("warning: %s", msg) })
+	if dryRunFlag.Bool() {
+		ctx, plan = wtff.DryRun(ctx)
+	} else if zli.IsTerminal(os.Stderr.Fd()) {
+		ctx = wtff.WithProgress(ctx, progressBar)
+	}
+
 	var cmdErr error
 	switch cmd {
 	case "help":
 		fmt.Print(usage)
 	case "info":
 		var (
-			meta = f.Bool(false, "m", "meta")
-			json = f.Bool(false, "j", "json")
+			meta      = f.Bool(false, "m", "meta")
+			json      = f.Bool(false, "j", "json")
+			keyframes = f.Bool(false, "k", "keyframes")
+			at        = f.String("", "at")
 		)
 		zli.F(f.Parse())
 		if len(f.Args) == 0 {
 			zli.Fatalf(`"info" command needs at least one file`)
 		}
-		cmdErr = cmdInfo(meta.Bool(), json.Bool(), f.Args...)
+		if at.Set() && !keyframes.Bool() {
+			zli.Fatalf("-at needs -keyframes")
+		}
+		if keyframes.Bool() && (meta.Bool() || json.Bool()) {
+			zli.Fatalf("-keyframes can't be combined with -m or -j")
+		}
+		if keyframes.Bool() {
+			cmdErr = cmdKeyframes(ctx, at.String(), f.Args...)
+		} else {
+			cmdErr = cmdInfo(ctx, meta.Bool(), json.Bool(), f.Args...)
+		}
 	case "meta":
 		var (
 			tomlFile = f.String("", "t", "toml-file")
 			write    = f.Bool(false, "w", "write")
 			strip    = f.IntCounter(0, "s", "strip")
+			cueFile  = f.String("", "cue")
 		)
 		zli.F(f.Parse())
 		if write.Set() && !tomlFile.Set() {
@@ -174,10 +456,17 @@ func main() {
 		if strip.Int() > 0 && (write.Set() || tomlFile.Set()) {
 			zli.Fatalf("-s can't be combined with -w or -t")
 		}
+		if cueFile.Set() && (strip.Int() > 0 || write.Set() || tomlFile.Set()) {
+			zli.Fatalf("-cue can't be combined with -s, -w, or -t")
+		}
 		if len(f.Args) != 1 {
 			zli.Fatalf(`"meta" command needs exactly one input file`)
 		}
-		cmdErr = cmdMeta(f.Args[0], tomlFile.String(), !write.Bool(), strip.Int())
+		if cueFile.Set() {
+			cmdErr = cmdMetaCue(ctx, f.Args[0], cueFile.String())
+		} else {
+			cmdErr = cmdMeta(ctx, f.Args[0], tomlFile.String(), !write.Bool(), strip.Int())
+		}
 	case "mb":
 		var (
 			artist  = f.String("", "artist")
@@ -188,11 +477,20 @@ func main() {
 		if len(f.Args) != 1 {
 			zli.Fatalf(`"mb" command needs exactly one input file`)
 		}
-		cmdErr = cmdMb(f.Args[0], artist.String(), album.String(), release.String())
+		cmdErr = cmdMb(ctx, f.Args[0], artist.String(), album.String(), release.String())
 	case "cat":
 		var (
-			force  = f.Bool(false, "f", "force")
-			output = f.String("", "-o", "output")
+			force     = f.Bool(false, "f", "force")
+			output    = f.String("", "-o", "output")
+			normalize = f.Bool(false, "normalize")
+			size      = f.String("", "size")
+			fps       = f.String("", "fps")
+			rate      = f.Int(0, "rate")
+			merge     = f.Bool(false, "merge-chapters")
+			nest      = f.Bool(false, "nest-chapters")
+			title     = f.String("", "title")
+			subs      = f.Bool(false, "subs")
+			order     = f.String("", "sort")
 		)
 		zli.F(f.Parse())
 		if output.String() == "" {
@@ -201,27 +499,110 @@ func main() {
 		if len(f.Args) < 1 {
 			zli.Fatalf("need at least one input file")
 		}
-		cmdErr = cmdCat(force.Bool(), output.String(), f.Args...)
+		if (size.Set() || fps.Set() || rate.Set()) && !normalize.Bool() {
+			zli.Fatalf("-size, -fps, and -rate need -normalize")
+		}
+		if nest.Bool() && !merge.Bool() {
+			zli.Fatalf("-nest-chapters needs -merge-chapters")
+		}
+		target, err := parseCatTarget(size.String(), fps.String(), rate.Int())
+		zli.F(err)
+		cmdErr = cmdCat(ctx, force.Bool(), output.String(), order.String(), title.String(), wtff.CatOptions{
+			MergeChapters: merge.Bool(),
+			NestChapters:  nest.Bool(),
+			Subs:          subs.Bool(),
+			Normalize:     normalize.Bool(),
+			Target:        target,
+		}, f.Args...)
 	case "cut":
 		var (
-			output = f.String("", "-o", "output")
+			output   = f.String("", "-o", "output")
+			remove   = f.Bool(false, "remove")
+			accurate = f.Bool(false, "a", "accurate")
+			srt      = f.String("", "srt")
 		)
 		zli.F(f.Parse())
 		if output.String() == "" {
 			zli.Fatalf("need to set output file with -o")
 		}
-		if len(f.Args) != 4 {
-			zli.Fatalf("usage: wtff cut [-o output] [input] [start] [verb] [stop]")
+		if len(f.Args) < 4 {
+			zli.Fatalf("usage: wtff cut [-o output] [-a] [-remove] [-srt file] [input] [start] [verb] [stop], ...")
 		}
-		cmdErr = cmdCut(f.Args[0], output.String(), f.Args[2], f.Args[1], f.Args[3])
+		cmdErr = cmdCut(ctx, f.Args[0], output.String(), srt.String(), remove.Bool(), accurate.Bool(), f.Args[1:])
+	case "split":
+		var (
+			cueFile  = f.String("", "cue")
+			chapters = f.Bool(false, "chapters")
+			every    = f.String("", "every")
+			size     = f.String("", "size")
+			output   = f.String("", "o", "output")
+		)
+		zli.F(f.Parse())
+		var mode, arg string
+		for _, m := range []struct {
+			set       bool
+			mode, arg string
+		}{
+			{cueFile.Set(), "cue", cueFile.String()},
+			{chapters.Bool(), "chapters", ""},
+			{every.Set(), "every", every.String()},
+			{size.Set(), "size", size.String()},
+		} {
+			if m.set && mode != "" {
+				zli.Fatalf("can only use one of -cue, -chapters, -every, or -size")
+			}
+			if m.set {
+				mode, arg = m.mode, m.arg
+			}
+		}
+		if mode == "" {
+			zli.Fatalf("need one of -cue, -chapters, -every, or -size")
+		}
+		if len(f.Args) != 1 {
+			zli.Fatalf(`"split" command needs exactly one input file`)
+		}
+		cmdErr = cmdSplit(ctx, f.Args[0], mode, arg, output.String())
+	case "chapters":
+		subCmd, err := f.ShiftCommand("export", "import")
+		zli.F(err)
+		cmdErr = cmdChapters(ctx, f, subCmd)
+	case "edit":
+		var (
+			output   = f.String("", "o", "output")
+			tomlFile = f.String("", "t", "toml")
+			addSub   = f.StringList(nil, "add-sub")
+			rmSub    = f.StringList(nil, "rm-sub")
+			addAudio = f.StringList(nil, "add-audio")
+			rmAudio  = f.StringList(nil, "rm-audio")
+			keep     = f.StringList(nil, "keep")
+			drop     = f.StringList(nil, "drop")
+			order    = f.StringList(nil, "order")
+			setDef   = f.StringList(nil, "default")
+		)
+		zli.F(f.Parse())
+		if len(f.Args) != 1 {
+			zli.Fatalf(`"edit" command needs exactly one input file`)
+		}
+		cmdErr = cmdEdit(ctx, f.Args[0], output.String(), tomlFile.String(),
+			addSub.Strings(), rmSub.Strings(), addAudio.Strings(), rmAudio.Strings(),
+			keep.Strings(), drop.Strings(), order.Strings(), setDef.Strings())
+	case "streams":
+		subCmd, err := f.ShiftCommand("keep", "drop", "order", "set-default", "set-forced", "unset-forced", "disposition")
+		zli.F(err)
+		cmdErr = cmdStreams(ctx, f, subCmd)
 	case "subs":
 		subCmd, err := f.ShiftCommand("add", "rm", "save", "replace", "print", "sync", "burn")
 		zli.F(err)
-		cmdErr = cmdSub(f, subCmd)
+		cmdErr = cmdSub(ctx, f, subCmd)
 	case "audio":
 		subCmd, err := f.ShiftCommand("add", "rm", "save", "replace")
 		zli.F(err)
-		cmdErr = cmdAudio(f, subCmd)
+		cmdErr = cmdAudio(ctx, f, subCmd)
 	}
 	zli.F(cmdErr)
+	if plan != nil {
+		if c := plan.String(); c != "" {
+			fmt.Println(c)
+		}
+	}
 }
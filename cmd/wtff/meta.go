@@ -11,16 +11,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"zgo.at/wtff"
 	"zgo.at/zstd/zfilepath"
 )
 
-// TODO: allow editing per-stream meta
-func cmdMeta(input, tomlFile string, editFile bool, strip int) error {
+func cmdMeta(ctx context.Context, input, tomlFile string, editFile bool, strip int) error {
 	if strip > 0 {
-		cur, err := wtff.ReadMeta(context.Background(), input)
+		cur, err := wtff.ReadMeta(ctx, input)
 		if err != nil {
 			return err
 		}
@@ -29,32 +29,17 @@ func cmdMeta(input, tomlFile string, editFile bool, strip int) error {
 			return nil
 		}
 
-		base, ext := zfilepath.SplitExt(input)
-		outTmp, err := os.CreateTemp(filepath.Dir(input), filepath.Base(base)+"-wtff-meta-*."+ext)
-		if err != nil {
-			return err
-		}
-		defer os.Remove(outTmp.Name())
-
 		var m wtff.Meta
 		if strip == 1 {
 			m.Chapters = cur.Chapters
 		}
-		err = wtff.WriteMeta(context.Background(), m, input, outTmp.Name())
-		if err != nil {
-			return err
-		}
-
-		err = os.Rename(outTmp.Name(), input)
-		if err != nil {
-			return err
-		}
-		return nil
+		return wtff.WriteMeta(ctx, m, input, input)
 	}
 
 	var (
 		oktoRm    = true
 		tomlMeta  string
+		duration  time.Duration
 		doErr     = func(err error) error { return err }
 		runEditor = func(p string) error {
 			editor := "vi"
@@ -62,16 +47,17 @@ func cmdMeta(input, tomlFile string, editFile bool, strip int) error {
 				editor = e
 			}
 
-			cmd := exec.CommandContext(context.Background(), editor, p)
+			cmd := exec.CommandContext(ctx, editor, p)
 			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
 			return cmd.Run()
 		}
 	)
 	if tomlFile == "" {
-		m, err := wtff.ReadMeta(context.Background(), input)
+		m, err := wtff.ReadMeta(ctx, input)
 		if err != nil {
 			return err
 		}
+		duration = m.Duration
 
 		tmp, err := os.CreateTemp("", "wtff-meta-*.toml")
 		if err != nil {
@@ -105,6 +91,12 @@ func cmdMeta(input, tomlFile string, editFile bool, strip int) error {
 		}
 		tomlMeta = string(n)
 	} else {
+		info, err := wtff.Probe(ctx, input)
+		if err != nil {
+			return err
+		}
+		duration = info.Format.Duration.Duration
+
 		if editFile {
 			err := runEditor(tomlFile)
 			if err != nil {
@@ -118,24 +110,12 @@ func cmdMeta(input, tomlFile string, editFile bool, strip int) error {
 		tomlMeta = string(n)
 	}
 
-	m, err := wtff.ParseMetaFromTOML(tomlMeta)
-	if err != nil {
-		return doErr(err)
-	}
-
-	base, ext := zfilepath.SplitExt(input)
-	outTmp, err := os.CreateTemp(filepath.Dir(input), filepath.Base(base)+"-wtff-meta-*."+ext)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(outTmp.Name())
-
-	err = wtff.WriteMeta(context.Background(), m, input, outTmp.Name())
+	m, err := wtff.ParseMetaFromTOML(tomlMeta, duration)
 	if err != nil {
 		return doErr(err)
 	}
 
-	err = os.Rename(outTmp.Name(), input)
+	err = wtff.WriteMeta(ctx, m, input, input)
 	if err != nil {
 		return doErr(err)
 	}
@@ -144,7 +124,15 @@ func cmdMeta(input, tomlFile string, editFile bool, strip int) error {
 	return nil
 }
 
-func cmdMb(input, artist, album, release string) error {
+func cmdMetaCue(ctx context.Context, input, cueFile string) error {
+	m, err := metaFromCue(ctx, input, cueFile)
+	if err != nil {
+		return err
+	}
+	return wtff.WriteMeta(ctx, m, input, input)
+}
+
+func cmdMb(ctx context.Context, input, artist, album, release string) error {
 	inp, _ := zfilepath.SplitExt(filepath.Base(input))
 	aa, al, ok := strings.Cut(inp, " - ")
 	if !ok {
@@ -157,11 +145,14 @@ func cmdMb(input, artist, album, release string) error {
 		album = al
 	}
 
-	m, err := wtff.ReadMeta(context.Background(), input)
+	m, err := wtff.ReadMeta(ctx, input)
 	if err != nil {
 		return err
 	}
-	info, err := wtff.Probe(context.Background(), input)
+	// Don't clutter the list of releases with the streams; this is only about
+	// the album tags.
+	m.Streams = nil
+	info, err := wtff.Probe(ctx, input)
 	if err != nil {
 		return err
 	}
@@ -210,7 +201,7 @@ func cmdMb(input, artist, album, release string) error {
 		return err
 	}
 
-	return cmdMeta(input, fp.Name(), true, 0)
+	return cmdMeta(ctx, input, fp.Name(), true, 0)
 }
 
 type (
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"zgo.at/wtff"
+	"zgo.at/zli"
+)
+
+func progressBar(p wtff.Progress) {
+	if p.Done {
+		fmt.Fprint(os.Stderr, "\x1b[2K\r")
+		return
+	}
+
+	stat := fmt.Sprintf(" %s  %.1fx", wtff.Time{Duration: p.OutTime.Truncate(time.Second)}, p.Speed)
+	if p.Duration == 0 {
+		fmt.Fprint(os.Stderr, "\x1b[2K\r"+stat)
+		return
+	}
+	stat = fmt.Sprintf(" %5.1f%%%s", p.Percent, stat)
+	if p.ETA > 0 {
+		stat += "  ETA " + wtff.Time{Duration: p.ETA}.String()
+	}
+
+	w, _, err := zli.TerminalSize(os.Stderr.Fd())
+	if err != nil || w < 40 {
+		w = 80
+	}
+	barW := max(w-len(stat)-3, 0)
+	fill := max(min(barW, int(p.Percent/100*float64(barW))), 0)
+	fmt.Fprintf(os.Stderr, "\x1b[2K\r[%s%s]%s", strings.Repeat("=", fill), strings.Repeat(" ", barW-fill), stat)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"zgo.at/wtff"
+	"zgo.at/zstd/zfilepath"
+)
+
+func cmdSplit(ctx context.Context, input, mode, arg, tmpl string) error {
+	base, ext := zfilepath.SplitExt(filepath.Base(input))
+
+	switch mode {
+	case "every", "size":
+		if tmpl == "" {
+			tmpl = "{name}-{n:02}.{ext}"
+		}
+		vars := func(n int) map[string]string {
+			return map[string]string{"n": strconv.Itoa(n), "name": base, "ext": ext}
+		}
+		if _, err := expandTemplate(tmpl, vars(1), safeFilename); err != nil {
+			return err
+		}
+		output := func(n int) string {
+			out, _ := expandTemplate(tmpl, vars(n), safeFilename)
+			return out
+		}
+
+		if mode == "every" {
+			every, err := wtff.ParseTime(arg, wtff.ProbeFile{})
+			if err != nil {
+				return err
+			}
+			return wtff.SplitEvery(ctx, input, every.Duration, output)
+		}
+		var size wtff.Byte
+		if err := size.UnmarshalText([]byte(arg)); err != nil {
+			return err
+		}
+		return wtff.SplitSize(ctx, input, size, output)
+	}
+
+	var (
+		m   wtff.Meta
+		err error
+	)
+	if mode == "cue" {
+		m, err = metaFromCue(ctx, input, arg)
+	} else {
+		m, err = wtff.ReadMeta(ctx, input)
+	}
+	if err != nil {
+		return err
+	}
+	if len(m.Chapters) == 0 {
+		return fmt.Errorf("%q has no chapters", input)
+	}
+
+	if tmpl == "" {
+		tmpl = "{n:02} {title}.{ext}"
+	}
+	vars := func(n int, c wtff.MetaChapter) map[string]string {
+		return map[string]string{
+			"n":      strconv.Itoa(n),
+			"total":  strconv.Itoa(len(m.Chapters)),
+			"title":  c.Title,
+			"artist": m.Artist,
+			"album":  m.Title,
+			"name":   base,
+			"ext":    ext,
+		}
+	}
+	// Check for errors before writing anything.
+	if _, err := expandTemplate(tmpl, vars(1, m.Chapters[0]), safeFilename); err != nil {
+		return err
+	}
+
+	return wtff.Split(ctx, input, m, func(n int, c wtff.MetaChapter) string {
+		out, _ := expandTemplate(tmpl, vars(n, c), safeFilename)
+		return out
+	})
+}
+
+// expandTemplate replaces {name} in tmpl with the value from vars; numbers can
+// be padded with zeroes with {name:02}. Values are passed through escape, if
+// it's not nil. Unknown {meta.*} variables are empty, as not all files have the
+// same tags.
+func expandTemplate(tmpl string, vars map[string]string, escape func(string) string) (string, error) {
+	var (
+		b    = new(strings.Builder)
+		orig = tmpl
+	)
+	for {
+		s, rest, ok := strings.Cut(tmpl, "{")
+		b.WriteString(s)
+		if !ok {
+			return b.String(), nil
+		}
+		v, rest, ok := strings.Cut(rest, "}")
+		if !ok {
+			return "", fmt.Errorf("unclosed { in template %q", orig)
+		}
+		tmpl = rest
+
+		name, pad, _ := strings.Cut(v, ":")
+		val, ok := vars[name]
+		if !ok && strings.HasPrefix(name, "meta.") {
+			ok = true
+		}
+		if !ok {
+			return "", fmt.Errorf("unknown template variable {%s}", name)
+		}
+		if pad != "" {
+			w, err := strconv.Atoi(pad)
+			n, err2 := strconv.Atoi(val)
+			if err != nil || err2 != nil {
+				return "", fmt.Errorf("invalid padding in {%s}", v)
+			}
+			val = fmt.Sprintf("%0*d", w, n)
+		}
+		if escape != nil {
+			val = escape(val)
+		}
+		b.WriteString(val)
+	}
+}
+
+var safeFilename = strings.NewReplacer("/", "-", "\x00", "").Replace
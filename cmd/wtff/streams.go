@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+
+	"zgo.at/wtff"
+	"zgo.at/zli"
+)
+
+func cmdStreams(ctx context.Context, f zli.Flags, cmd string) error {
+	output := f.String("", "o", "output")
+	zli.F(f.Parse())
+	switch {
+	case cmd == "disposition" && len(f.Args) != 3:
+		zli.Fatalf("usage: wtff streams disposition [-o output] [input] [stream] [disposition]")
+	case cmd == "order" && len(f.Args) < 2:
+		zli.Fatalf("usage: wtff streams order [-o output] [input] [stream...]")
+	case cmd != "order" && cmd != "disposition" && len(f.Args) != 2:
+		zli.Fatalf("usage: wtff streams %s [-o output] [input] [stream]", cmd)
+	}
+	out := output.String()
+	if out == "" {
+		out = f.Args[0]
+	}
+
+	e := wtff.Edit(f.Args[0])
+	switch cmd {
+	case "keep":
+		e.KeepStreams(f.Args[1])
+	case "drop":
+		e.DropStreams(f.Args[1])
+	case "order":
+		e.OrderStreams(f.Args[1:]...)
+	case "set-default":
+		e.SetDefault(f.Args[1])
+	case "set-forced":
+		e.SetDisposition(f.Args[1], "+forced")
+	case "unset-forced":
+		e.SetDisposition(f.Args[1], "-forced")
+	case "disposition":
+		e.SetDisposition(f.Args[1], f.Args[2])
+	}
+	return e.Write(ctx, out)
+}
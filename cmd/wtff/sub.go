@@ -13,23 +13,24 @@ import (
 	"zgo.at/zli"
 )
 
-func cmdSub(f zli.Flags, cmd string) error {
+func cmdSub(ctx context.Context, f zli.Flags, cmd string) error {
 	switch cmd {
 	case "add":
 		var (
 			lang = f.String("", "l", "lang")
+			disp = f.StringList(nil, "d", "disposition")
 		)
 		zli.F(f.Parse())
 		if len(f.Args) != 2 {
-			zli.Fatalf("usage: wtff sub add [-l lang] [media] [sub-file]")
+			zli.Fatalf("usage: wtff sub add [-l lang] [-d disposition] [media] [sub-file]")
 		}
-		return cmdSubAdd(f.Args[0], f.Args[1], lang.String())
+		return cmdSubAdd(ctx, f.Args[0], f.Args[1], lang.String(), disp.StringsSplit(",")...)
 	case "rm":
 		zli.F(f.Parse())
 		if len(f.Args) != 2 {
 			zli.Fatalf("usage: wtff sub rm [media] [stream]")
 		}
-		return cmdSubRm(f.Args[0], f.Args[1])
+		return cmdSubRm(ctx, f.Args[0], f.Args[1])
 	case "save":
 		var (
 			output = f.String("", "o", "output")
@@ -38,13 +39,13 @@ func cmdSub(f zli.Flags, cmd string) error {
 		if len(f.Args) != 2 {
 			zli.Fatalf("usage: wtff sub save [-o output] [media] [stream]")
 		}
-		return cmdSubSave(f.Args[0], f.Args[1], output.String())
+		return cmdSubSave(ctx, f.Args[0], f.Args[1], output.String())
 	case "print":
 		zli.F(f.Parse())
 		if len(f.Args) != 2 {
 			zli.Fatalf("usage: wtff sub print [media] [stream]")
 		}
-		return cmdSubPrint(f.Args[0], f.Args[1])
+		return cmdSubPrint(ctx, f.Args[0], f.Args[1])
 	case "replace":
 		zli.F(f.Parse())
 		return nil // TODO
@@ -74,30 +75,30 @@ func cmdSub(f zli.Flags, cmd string) error {
 	panic("unreachable")
 }
 
-func cmdSubAdd(input, subFile, lang string) error {
+func cmdSubAdd(ctx context.Context, input, subFile, lang string, disp ...string) error {
 	nosub := []string{".avi"}
 	if i := slices.Index(nosub, filepath.Ext(input)); i > -1 {
 		return fmt.Errorf("%q format does not support subtitles", nosub[i])
 	}
-	return wtff.SubAdd(context.Background(), input, subFile, lang)
+	return wtff.SubAdd(ctx, input, subFile, lang, disp...)
 }
 
-func cmdSubRm(input, stream string) error {
-	return wtff.SubRm(context.Background(), input, stream)
+func cmdSubRm(ctx context.Context, input, stream string) error {
+	return wtff.SubRm(ctx, input, stream)
 }
 
-func cmdSubSave(input, stream, output string) error {
-	return wtff.SubSave(context.Background(), input, stream, output, false)
+func cmdSubSave(ctx context.Context, input, stream, output string) error {
+	return wtff.SubSave(ctx, input, stream, output, false)
 }
 
-func cmdSubPrint(input, stream string) error {
+func cmdSubPrint(ctx context.Context, input, stream string) error {
 	tmp, err := os.CreateTemp("", "wtff.*.srt")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tmp.Name())
 
-	err = wtff.SubSave(context.Background(), input, stream, tmp.Name(), true)
+	err = wtff.SubSave(ctx, input, stream, tmp.Name(), true)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,336 @@
+package wtff
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// ConcatReport is a report of the differences between files that would
+	// make it impossible to concatenate them without re-encoding; see
+	// CheckConcat.
+	ConcatReport struct {
+		Inputs   []string
+		Problems []ConcatProblem
+	}
+
+	// ConcatProblem is a single difference between an input file and the first
+	// input file.
+	ConcatProblem struct {
+		Input  int    // Index in ConcatReport.Inputs.
+		Stream int    // Stream index, or -1 for the file as a whole.
+		Field  string // "codec", "profile", "sample_rate", etc.
+		Want   string // Value in the first input.
+		Have   string // Value in this input.
+
+		// Fatal problems result in a broken file; others are "cosmetic"
+		// problems that may cause playback issues in some players, such as
+		// the wrong aspect ratio.
+		Fatal bool
+	}
+)
+
+func (p ConcatProblem) String() string {
+	s := fmt.Sprintf("%s is %q instead of %q", p.Field, p.Have, p.Want)
+	if p.Stream > -1 {
+		s = "stream " + strconv.Itoa(p.Stream) + ": " + s
+	}
+	if !p.Fatal {
+		s += " (cosmetic)"
+	}
+	return s
+}
+
+// OK reports if there are no fatal problems.
+func (r ConcatReport) OK() bool {
+	for _, p := range r.Problems {
+		if p.Fatal {
+			return false
+		}
+	}
+	return true
+}
+
+// String gets the list of problems, grouped by input file.
+func (r ConcatReport) String() string {
+	b := new(strings.Builder)
+	last := -1
+	for _, p := range r.Problems {
+		if p.Input != last {
+			b.WriteString(r.Inputs[p.Input] + "\n")
+			last = p.Input
+		}
+		b.WriteString("    " + p.String() + "\n")
+	}
+	return b.String()
+}
+
+// CheckConcat checks if all inputs can be concatenated with Cat, by comparing
+// the streams of every input to the first input.
+//
+// Attachments (e.g. fonts) are ignored. The streams must be identical in
+// number and order, and video and audio streams must have the same codec
+// parameters. Subtitle and data streams only need the same codec.
+//
+// This is the same as CheckConcatWith with the default options.
+func CheckConcat(ctx context.Context, inputs ...string) (ConcatReport, error) {
+	return CheckConcatWith(ctx, CatOptions{}, inputs...)
+}
+
+// CheckConcatWith checks if all inputs can be concatenated with CatWith and
+// the given options, like CheckConcat.
+//
+// Subtitle streams are ignored if opt.Subs is set, as they're joined
+// separately.
+func CheckConcatWith(ctx context.Context, opt CatOptions, inputs ...string) (ConcatReport, error) {
+	r := ConcatReport{Inputs: inputs}
+	if len(inputs) == 0 {
+		return r, nil
+	}
+
+	var first Streams
+	for i, input := range inputs {
+		info, err := Probe(ctx, input)
+		if err != nil {
+			return r, fmt.Errorf("wtff.CheckConcat: %w", err)
+		}
+		streams := make(Streams, 0, len(info.Streams))
+		for _, s := range info.Streams {
+			if s.CodecType != "attachment" && !(opt.Subs && s.Subtitle()) {
+				streams = append(streams, s)
+			}
+		}
+		if i == 0 {
+			first = streams
+			continue
+		}
+		r.Problems = append(r.Problems, compareStreams(i, first, streams)...)
+	}
+	return r, nil
+}
+
+func compareStreams(input int, want, have Streams) []ConcatProblem {
+	var problems []ConcatProblem
+	add := func(stream int, field, w, h string, fatal bool) {
+		if w != h {
+			problems = append(problems, ConcatProblem{Input: input, Stream: stream, Field: field, Want: w, Have: h, Fatal: fatal})
+		}
+	}
+
+	types := func(s Streams) string {
+		t := make([]string, 0, len(s))
+		for _, ss := range s {
+			t = append(t, ss.CodecType)
+		}
+		return strings.Join(t, ", ")
+	}
+	add(-1, "streams", types(want), types(have), true)
+
+	for i := range min(len(want), len(have)) {
+		w, h := want[i], have[i]
+		if w.CodecType != h.CodecType {
+			continue // Already reported as "streams".
+		}
+		add(h.Index, "codec", w.CodecName, h.CodecName, true)
+		if w.CodecName != h.CodecName {
+			continue
+		}
+		switch w.CodecType {
+		case "video":
+			add(h.Index, "profile", w.Profile, h.Profile, true)
+			add(h.Index, "pix_fmt", w.PixFmt, h.PixFmt, true)
+			add(h.Index, "resolution", fmt.Sprintf("%d×%d", w.Width, w.Height), fmt.Sprintf("%d×%d", h.Width, h.Height), true)
+			add(h.Index, "sample_aspect_ratio", w.SampleAspectRatio, h.SampleAspectRatio, false)
+			add(h.Index, "frame_rate", w.AvgFrameRate, h.AvgFrameRate, false)
+			add(h.Index, "time_base", w.TimeBase, h.TimeBase, false)
+		case "audio":
+			add(h.Index, "profile", w.Profile, h.Profile, true)
+			add(h.Index, "sample_rate", w.SampleRate, h.SampleRate, true)
+			add(h.Index, "channels", strconv.Itoa(int(w.Channels)), strconv.Itoa(int(h.Channels)), true)
+			if w.Channels == h.Channels {
+				add(h.Index, "channel_layout", w.ChannelLayout, h.ChannelLayout, true)
+			}
+			add(h.Index, "time_base", w.TimeBase, h.TimeBase, false)
+		}
+	}
+	return problems
+}
+
+// CatTarget is the format to convert inputs to with CatNormalize. Zero values
+// are taken from the first input.
+type CatTarget struct {
+	Width, Height int
+	FrameRate     string // As a number or fraction, e.g. "30" or "30000/1001".
+	SampleRate    int
+}
+
+// CatNormalize concatenates all inputs like Cat, but first converts the inputs
+// that can't be concatenated as-is to match the target. If the video of any
+// input needs to be re-encoded then the video of all inputs is, as copied and
+// re-encoded video can't be joined reliably.
+//
+// This is the same as CatWith with Normalize set.
+func CatNormalize(ctx context.Context, output string, target CatTarget, input ...string) error {
+	return CatWith(ctx, output, CatOptions{Normalize: true, Target: target}, input...)
+}
+
+// normalize converts the inputs that can't be concatenated as-is to temporary
+// files. It returns the list of files to concatenate, the temporary files, and
+// the ffmpeg flags to map the streams.
+func normalize(ctx context.Context, output string, target CatTarget, input []string) ([]string, []string, []string, error) {
+	infos := make([]ProbeFile, 0, len(input))
+	for _, in := range input {
+		info, err := Probe(ctx, in)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("wtff.CatNormalize: %w", err)
+		}
+		infos = append(infos, info)
+	}
+
+	// The output streams: video first, and then audio.
+	var want Streams
+	if v := infos[0].Streams.videoStream(); v > -1 {
+		s := infos[0].Streams[v]
+		if target.Width > 0 && target.Height > 0 {
+			s.Width, s.Height = uint(target.Width), uint(target.Height)
+		}
+		if target.FrameRate != "" {
+			s.AvgFrameRate = target.FrameRate
+		}
+		want = append(want, s)
+	}
+	if a := infos[0].Streams.audioStream(); a > -1 {
+		s := infos[0].Streams[a]
+		if target.SampleRate > 0 {
+			s.SampleRate = strconv.Itoa(target.SampleRate)
+		}
+		want = append(want, s)
+	}
+	if len(want) == 0 {
+		return nil, nil, nil, fmt.Errorf("wtff.CatNormalize: %q has no video or audio", input[0])
+	}
+	for i := range want {
+		want[i].Index = i
+	}
+
+	// A re-encoded video stream never has exactly the same codec parameters as
+	// the original, and the concat demuxer uses the parameters of the first
+	// file for all of them. So re-encode all video if any of it needs to be.
+	var encodeVideo bool
+	if want[0].Video() {
+		for _, info := range infos {
+			if v := info.Streams.videoStream(); v > -1 && needsEncode(want[0], info.Streams[v], target) {
+				encodeVideo = true
+				break
+			}
+		}
+	}
+
+	var (
+		files = make([]string, 0, len(input))
+		tmp   []string
+	)
+	for i, in := range input {
+		args, err := normalizeArgs(in, infos[i], want, target, encodeVideo)
+		if err != nil {
+			return nil, tmp, nil, fmt.Errorf("wtff.CatNormalize: %q: %w", in, err)
+		}
+		if args == nil {
+			files = append(files, in)
+			continue
+		}
+
+		part, err := tmpPart(ctx, filepath.Dir(output), "mkv")
+		if err != nil {
+			return nil, tmp, nil, fmt.Errorf("wtff.CatNormalize: %w", err)
+		}
+		tmp = append(tmp, part)
+		_, err = ffmpegProgress(ctx, "wtff.CatNormalize", infos[i].Format.Duration.Duration, append(args, part)...)
+		if err != nil {
+			return nil, tmp, nil, err
+		}
+		files = append(files, part)
+	}
+
+	maps := make([]string, 0, len(want)*2)
+	for i := range want {
+		maps = append(maps, "-map", "0:"+strconv.Itoa(i))
+	}
+	return files, tmp, maps, nil
+}
+
+// needsEncode reports if the stream have needs to be re-encoded to match want.
+func needsEncode(want, have Stream, target CatTarget) bool {
+	if want.Video() && target.FrameRate != "" && math.Abs(want.FrameRate()-have.FrameRate()) > 0.01 {
+		return true
+	}
+	for _, p := range compareStreams(0, Streams{want}, Streams{have}) {
+		if p.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeArgs gets the ffmpeg flags to convert the input to the streams in
+// want, or nil if it can be used as-is. The video is always re-encoded if
+// encodeVideo is set.
+func normalizeArgs(input string, info ProbeFile, want Streams, target CatTarget, encodeVideo bool) ([]string, error) {
+	var (
+		in      = []string{"-y", "-i", input}
+		args    []string
+		convert bool
+	)
+	for _, w := range want {
+		n := strconv.Itoa(w.Index)
+		idx := info.Streams.audioStream()
+		if w.Video() {
+			idx = info.Streams.videoStream()
+		}
+		if idx == -1 {
+			if w.Video() {
+				return nil, fmt.Errorf("no video stream")
+			}
+			layout := w.ChannelLayout
+			if layout == "" {
+				layout = "stereo"
+			}
+			in = append(in, "-f", "lavfi", "-i", "anullsrc=channel_layout="+layout+":sample_rate="+w.SampleRate)
+			args = append(args, "-map", "1:a", "-shortest")
+			args = append(args, encodeArgs(w)...)
+			convert = true
+			continue
+		}
+
+		have := info.Streams[idx]
+		args = append(args, "-map", "0:"+strconv.Itoa(idx))
+		if !(w.Video() && encodeVideo) && !needsEncode(w, have, target) {
+			args = append(args, "-c:"+n, "copy")
+			convert = convert || idx != w.Index
+			continue
+		}
+
+		convert = true
+		args = append(args, encodeArgs(w)...)
+		if w.Video() {
+			sar := strings.ReplaceAll(w.SampleAspectRatio, ":", "/")
+			if sar == "" || sar == "0/1" {
+				sar = "1"
+			}
+			vf := fmt.Sprintf("scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease,pad=%[1]d:%[2]d:-1:-1,setsar=%[3]s",
+				w.Width, w.Height, sar)
+			if target.FrameRate != "" {
+				vf += ",fps=" + target.FrameRate
+			}
+			args = append(args, "-filter:"+n, vf)
+		}
+	}
+	if !convert {
+		return nil, nil
+	}
+	return append(in, args...), nil
+}
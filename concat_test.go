@@ -0,0 +1,89 @@
+package wtff
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCatNormalize(t *testing.T) {
+	probe := map[string]string{
+		"a.mp4": `{"index":0,"codec_name":"h264","codec_type":"video","profile":"High","pix_fmt":"yuv420p","width":1920,"height":1080,"avg_frame_rate":"30/1"}`,
+		"b.mp4": `{"index":0,"codec_name":"h264","codec_type":"video","profile":"High","pix_fmt":"yuv420p","width":1920,"height":1080,"avg_frame_rate":"30/1"}`,
+		"c.mp4": `{"index":0,"codec_name":"h264","codec_type":"video","profile":"High","pix_fmt":"yuv420p","width":1280,"height":720,"avg_frame_rate":"30/1"}`,
+	}
+	r := &FakeRunner{Output: func(prog string, args []string) (string, string, error) {
+		if prog == "ffprobe" {
+			return `{"format":{"duration":"10.000000"},"streams":[` + probe[args[len(args)-1]] +
+				`,{"index":1,"codec_name":"aac","codec_type":"audio","profile":"LC","sample_rate":"48000","channels":2,"channel_layout":"stereo"}]}`, "", nil
+		}
+		return fakeOutput(prog, args), "", nil
+	}}
+
+	tests := []struct {
+		name  string
+		input []string
+		want  int
+	}{
+		{"compatible", []string{"a.mp4", "b.mp4"}, 0},
+		{"one different", []string{"a.mp4", "b.mp4", "c.mp4"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, plan := DryRun(WithRunner(context.Background(), r))
+			err := CatNormalize(ctx, "out.mp4", CatTarget{}, tt.input...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var n int
+			for _, c := range plan.Commands() {
+				if !strings.Contains(c, " -i ") || strings.Contains(c, "-f concat") {
+					continue
+				}
+				n++
+				if !strings.Contains(c, "-c:0 libx264 -pix_fmt:0 yuv420p -profile:0 high") || !strings.Contains(c, "-c:1 copy") {
+					t.Errorf("wrong args: %s", c)
+				}
+				if !strings.Contains(c, "scale=1920:1080:") {
+					t.Errorf("not scaled: %s", c)
+				}
+			}
+			if n != tt.want {
+				t.Errorf("re-encoded %d inputs, want %d\n%s", n, tt.want, plan)
+			}
+		})
+	}
+}
+
+func TestCheckConcatSubs(t *testing.T) {
+	probe := map[string]string{
+		"a.mkv": `{"index":1,"codec_name":"subrip","codec_type":"subtitle"}`,
+		"b.mkv": `{"index":1,"codec_name":"subrip","codec_type":"subtitle"},{"index":2,"codec_name":"hdmv_pgs_subtitle","codec_type":"subtitle"}`,
+		"c.mkv": ``,
+	}
+	r := &FakeRunner{Output: func(prog string, args []string) (string, string, error) {
+		s := `{"index":0,"codec_name":"aac","codec_type":"audio","sample_rate":"48000","channels":2}`
+		if p := probe[args[len(args)-1]]; p != "" {
+			s += "," + p
+		}
+		return `{"format":{"duration":"10.000000"},"streams":[` + s + `]}`, "", nil
+	}}
+	ctx := WithRunner(context.Background(), r)
+
+	report, err := CheckConcat(ctx, "a.mkv", "b.mkv", "c.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() {
+		t.Errorf("no problems without Subs:\n%s", report)
+	}
+
+	report, err = CheckConcatWith(ctx, CatOptions{Subs: true}, "a.mkv", "b.mkv", "c.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Errorf("problems with Subs:\n%s", report)
+	}
+}
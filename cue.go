@@ -0,0 +1,171 @@
+package wtff
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"zgo.at/zstd/zmap"
+)
+
+// ParseCue parses a CUE sheet.
+//
+// The album PERFORMER, TITLE, and "REM DATE" are set as the artist, title, and
+// date, and every TRACK is a chapter starting at INDEX 01. The track PERFORMER
+// is prepended to the chapter title as "performer - title" if it's different
+// from the album PERFORMER, as chapters have no separate performer; Meta.Cue()
+// writes this back as the TITLE, and not as the track PERFORMER. Other "REM"
+// comments are added to Meta.Other.
+//
+// Only CUE sheets with a single FILE are supported, as chapters can't span
+// files. The End of the chapters is set to the start of the next chapter; it's
+// 0 for the last chapter, which Editor.Write() and WriteMeta() set to the end
+// of the file.
+func ParseCue(input string) (Meta, error) {
+	var (
+		m         = Meta{Other: make(map[string]string)}
+		track     = -1
+		performer []string // Per chapter.
+		files     int
+	)
+	input = strings.TrimPrefix(input, "\ufeff")
+	for i, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		cmd, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		switch strings.ToUpper(cmd) {
+		case "REM":
+			k, v, _ := strings.Cut(args, " ")
+			k, v = strings.ToLower(k), cueUnquote(v)
+			switch {
+			case track > -1 || v == "":
+			case k == "date":
+				m.Date = v
+			default:
+				m.Other[k] = v
+			}
+		case "FILE":
+			files++
+			if files > 1 {
+				return m, fmt.Errorf("wtff.ParseCue: line %d: more than one FILE is not supported", i+1)
+			}
+		case "PERFORMER":
+			if track > -1 {
+				performer[track] = cueUnquote(args)
+			} else {
+				m.Artist = cueUnquote(args)
+			}
+		case "TITLE":
+			if track > -1 {
+				m.Chapters[track].Title = cueUnquote(args)
+			} else {
+				m.Title = cueUnquote(args)
+			}
+		case "TRACK":
+			m.Chapters = append(m.Chapters, MetaChapter{Timebase: [2]int64{1, 1000}, Start: -1})
+			performer = append(performer, "")
+			track++
+		case "INDEX":
+			if track == -1 {
+				return m, fmt.Errorf("wtff.ParseCue: line %d: INDEX outside of TRACK", i+1)
+			}
+			n, t, _ := strings.Cut(args, " ")
+			if n != "01" {
+				continue
+			}
+			ms, err := parseCueTime(strings.TrimSpace(t))
+			if err != nil {
+				return m, fmt.Errorf("wtff.ParseCue: line %d: %w", i+1, err)
+			}
+			m.Chapters[track].Start = ms
+		}
+	}
+
+	for i, c := range m.Chapters {
+		if c.Start == -1 {
+			return m, fmt.Errorf("wtff.ParseCue: track %d has no INDEX 01", i+1)
+		}
+		if i > 0 && m.Chapters[i-1].Start > c.Start {
+			return m, fmt.Errorf("wtff.ParseCue: track %d starts before the preceding track", i+1)
+		}
+		if performer[i] != "" && performer[i] != m.Artist {
+			m.Chapters[i].Title = performer[i] + " - " + c.Title
+		}
+		if i < len(m.Chapters)-1 {
+			m.Chapters[i].End = m.Chapters[i+1].Start
+		}
+	}
+	return m, nil
+}
+
+func cueUnquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseCueTime parses "MM:SS:FF" to milliseconds; there are 75 frames per
+// second.
+func parseCueTime(t string) (int64, error) {
+	sp := strings.Split(t, ":")
+	if len(sp) != 3 {
+		return 0, fmt.Errorf("invalid time %q: not in the format MM:SS:FF", t)
+	}
+	var n [3]int64
+	for i, s := range sp {
+		var err error
+		n[i], err = strconv.ParseInt(s, 10, 64)
+		if err != nil || n[i] < 0 {
+			return 0, fmt.Errorf("invalid time %q: not in the format MM:SS:FF", t)
+		}
+	}
+	if n[1] >= 60 || n[2] >= 75 {
+		return 0, fmt.Errorf("invalid time %q: seconds must be below 60 and frames below 75", t)
+	}
+	return (n[0]*60+n[1])*1000 + (n[2]*1000+37)/75, nil
+}
+
+// Cue gets the chapters as a CUE sheet, for the given filename.
+//
+// Only the album has a PERFORMER; a track PERFORMER read with ParseCue is only
+// preserved as part of the TITLE.
+func (m Meta) Cue(file string) string {
+	b := new(strings.Builder)
+	q := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, "'") + `"` }
+
+	if m.Date != "" {
+		fmt.Fprintf(b, "REM DATE %s\n", m.Date)
+	}
+	for _, k := range zmap.KeysOrdered(m.Other) {
+		switch strings.ToLower(k) {
+		case "genre", "discid", "comment":
+			fmt.Fprintf(b, "REM %s %s\n", strings.ToUpper(k), q(m.Other[k]))
+		}
+	}
+	if m.Artist != "" {
+		fmt.Fprintf(b, "PERFORMER %s\n", q(m.Artist))
+	}
+	if m.Title != "" {
+		fmt.Fprintf(b, "TITLE %s\n", q(m.Title))
+	}
+	typ := "WAVE"
+	if strings.EqualFold(filepath.Ext(file), ".mp3") {
+		typ = "MP3"
+	}
+	fmt.Fprintf(b, "FILE %s %s\n", q(filepath.Base(file)), typ)
+	for i, c := range m.Chapters {
+		f := (c.StartTime().Milliseconds()*75 + 500) / 1000
+		fmt.Fprintf(b, "  TRACK %02d AUDIO\n", i+1)
+		if c.Title != "" {
+			fmt.Fprintf(b, "    TITLE %s\n", q(c.Title))
+		}
+		fmt.Fprintf(b, "    INDEX 01 %02d:%02d:%02d\n", f/75/60, f/75%60, f%75)
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package wtff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCue(t *testing.T) {
+	in := "\ufeff" + `REM GENRE "Jazz"
+REM DATE 1959
+REM COMMENT "ExactAudioCopy v1.6"
+PERFORMER "Miles Davis"
+TITLE "Kind of Blue"
+FILE "Kind of Blue.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "So What"
+    PERFORMER "Miles Davis"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Freddie Freeloader"
+    INDEX 00 09:20:50
+    INDEX 01 09:22:00
+  TRACK 03 AUDIO
+    TITLE "Blue in Green"
+    PERFORMER "Bill Evans"
+    INDEX 01 19:11:03
+`
+	want := Meta{
+		Title:  "Kind of Blue",
+		Artist: "Miles Davis",
+		Date:   "1959",
+		Other:  map[string]string{"genre": "Jazz", "comment": "ExactAudioCopy v1.6"},
+		Chapters: []MetaChapter{
+			{Timebase: [2]int64{1, 1000}, Start: 0, End: 562000, Title: "So What"},
+			{Timebase: [2]int64{1, 1000}, Start: 562000, End: 1151040, Title: "Freddie Freeloader"},
+			{Timebase: [2]int64{1, 1000}, Start: 1151040, End: 0, Title: "Bill Evans - Blue in Green"},
+		},
+	}
+
+	for _, nl := range []string{"\n", "\r\n"} {
+		have, err := ParseCue(strings.ReplaceAll(in, "\n", nl))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(normalMeta(have), normalMeta(want)) {
+			t.Errorf("\nhave: %#v\nwant: %#v", have, want)
+		}
+	}
+}
+
+func TestParseCueError(t *testing.T) {
+	tests := []struct {
+		in, wantErr string
+	}{
+		{"FILE \"a.wav\" WAVE\n  TRACK 01 AUDIO\n    TITLE \"x\"\n", "track 1 has no INDEX 01"},
+		{"FILE \"a.wav\" WAVE\n  TRACK 01 AUDIO\n    INDEX 00 00:00:00\n", "track 1 has no INDEX 01"},
+		{"FILE \"a.wav\" WAVE\nFILE \"b.wav\" WAVE\n", "line 2: more than one FILE"},
+		{"INDEX 01 00:00:00\n", "line 1: INDEX outside of TRACK"},
+		{"TRACK 01 AUDIO\nINDEX 01 00:10:75\n", "line 2: invalid time"},
+		{"TRACK 01 AUDIO\nINDEX 01 00:10:99\n", "line 2: invalid time"},
+		{"TRACK 01 AUDIO\nINDEX 01 00:60:00\n", "line 2: invalid time"},
+		{"TRACK 01 AUDIO\nINDEX 01 00:10\n", "line 2: invalid time"},
+		{"TRACK 01 AUDIO\nINDEX 01 00:-1:00\n", "line 2: invalid time"},
+		{"TRACK 01 AUDIO\nINDEX 01 01:00:00\nTRACK 02 AUDIO\nINDEX 01 00:30:00\n", "track 2 starts before"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseCue(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCue(t *testing.T) {
+	m := Meta{
+		Title:  `Say "Hello"`,
+		Artist: "Someone",
+		Date:   "2024",
+		Other:  map[string]string{"genre": "Rock", "encoder": "not written"},
+		Chapters: []MetaChapter{
+			{Timebase: [2]int64{1, 1000}, Start: 0, End: 61000, Title: "One"},
+			{Timebase: [2]int64{1, 1000}, Start: 61000, End: 0, Title: "Two"},
+		},
+	}
+	want := `REM DATE 2024
+REM GENRE "Rock"
+PERFORMER "Someone"
+TITLE "Say 'Hello'"
+FILE "file.mp3" MP3
+  TRACK 01 AUDIO
+    TITLE "One"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Two"
+    INDEX 01 01:01:00
+`
+	if have := m.Cue("/dir/file.mp3"); have != want {
+		t.Errorf("\nhave:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+func TestCueRoundTrip(t *testing.T) {
+	tests := []string{
+		"FILE \"a.wav\" WAVE\n",
+		"\ufeffREM DATE 2001\nREM DISCID 860B640B\nPERFORMER \"A\"\nTITLE \"B\"\nFILE \"a.wav\" WAVE\n" +
+			"  TRACK 01 AUDIO\n    INDEX 01 00:00:00\n" +
+			"  TRACK 02 AUDIO\n    TITLE \"Two\"\n    PERFORMER \"Guest\"\n    INDEX 01 03:15:40\n" +
+			"  TRACK 03 AUDIO\n    TITLE \"Three\"\n    PERFORMER \"A\"\n    INDEX 01 70:00:74\n",
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			m, err := ParseCue(tt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := m.Cue("a.wav")
+			have, err := ParseCue(c)
+			if err != nil {
+				t.Fatalf("%s\n%s", err, c)
+			}
+			if !reflect.DeepEqual(normalMeta(have), normalMeta(m)) {
+				t.Errorf("\nhave: %#v\nwant: %#v\n%s", have, m, c)
+			}
+		})
+	}
+}
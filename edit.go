@@ -0,0 +1,421 @@
+package wtff
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"zgo.at/zstd/zmap"
+)
+
+// Editor combines several edits in to one ffmpeg invocation, so the file only
+// needs to be written once.
+//
+// For example, to remove the German audio track, add an English subtitle, and
+// set the metadata:
+//
+//	err := wtff.Edit(input).RemoveAudio("ger").AddSub("en.srt", "eng").SetMeta(m).Write(ctx, output)
+type Editor struct {
+	op       string
+	input    string
+	drop     []editSel
+	keep     []string
+	order    []string
+	disp     []editDisp
+	addSub   []editStream
+	addAudio []editStream
+	meta     *Meta
+}
+
+type (
+	editStream struct{ file, lang, title, disp string }
+	editSel    struct{ kind, sel string }
+	editDisp   struct {
+		sel, disp string
+		only      bool // Remove disp from other streams of the same type.
+	}
+)
+
+// Edit starts a new set of edits for input; nothing is done until Write() is
+// called.
+func Edit(input string) *Editor {
+	return &Editor{op: "wtff.Edit", input: input}
+}
+
+// AddSub adds a subtitle from subFile, with the optional language and
+// dispositions (e.g. "default", "forced").
+func (e *Editor) AddSub(subFile, lang string, disposition ...string) *Editor {
+	e.addSub = append(e.addSub, editStream{file: subFile, lang: lang, disp: strings.Join(disposition, "+")})
+	return e
+}
+
+// RemoveSub removes all subtitle streams matching the selector; see Selector
+// for the syntax. Use "ALL" to remove all subtitles.
+func (e *Editor) RemoveSub(stream string) *Editor {
+	e.drop = append(e.drop, editSel{"subtitle", stream})
+	return e
+}
+
+// AddAudio adds an audio track from audioFile, with the optional language,
+// title, and dispositions (e.g. "default", "comment").
+func (e *Editor) AddAudio(audioFile, lang, title string, disposition ...string) *Editor {
+	e.addAudio = append(e.addAudio, editStream{file: audioFile, lang: lang, title: title, disp: strings.Join(disposition, "+")})
+	return e
+}
+
+// RemoveAudio removes all audio tracks matching the selector; see Selector for
+// the syntax. Use "ALL" to remove all audio tracks.
+func (e *Editor) RemoveAudio(stream string) *Editor {
+	e.drop = append(e.drop, editSel{"audio", stream})
+	return e
+}
+
+// DropStreams removes all streams matching the selector; see Selector for the
+// syntax.
+func (e *Editor) DropStreams(sel string) *Editor {
+	e.drop = append(e.drop, editSel{"", sel})
+	return e
+}
+
+// KeepStreams removes all streams that don't match the selector, but only for
+// the stream types the selector matches; all other streams are kept.
+//
+// For example "a:eng,a:jpn" removes all audio tracks that aren't English or
+// Japanese, but keeps all video, subtitle, and attachment streams.
+func (e *Editor) KeepStreams(sel string) *Editor {
+	e.keep = append(e.keep, sel)
+	return e
+}
+
+// OrderStreams puts the streams matching the selectors first, in the order of
+// the selectors. All other streams are kept in their original order after
+// that.
+func (e *Editor) OrderStreams(sel ...string) *Editor {
+	e.order = append(e.order, sel...)
+	return e
+}
+
+// SetDisposition sets the disposition of all streams matching the selector.
+//
+// The disposition is in the same format as ffmpeg's -disposition: flags joined
+// with "+", which replace the current dispositions (e.g. "default+forced"), a
+// "+" or "-" prefix to add or remove a flag (e.g. "+forced" or "-default"), or
+// "0" to clear everything.
+//
+// See Disposition for a list of flags.
+func (e *Editor) SetDisposition(sel, disposition string) *Editor {
+	e.disp = append(e.disp, editDisp{sel: sel, disp: disposition})
+	return e
+}
+
+// SetDefault sets the "default" disposition on the streams matching the
+// selector, and removes it from all other streams of the same type.
+func (e *Editor) SetDefault(sel string) *Editor {
+	e.disp = append(e.disp, editDisp{sel: sel, disp: "default", only: true})
+	return e
+}
+
+// SetMeta replaces the metadata and chapters with m, and the metadata of the
+// streams in m.Streams.
+func (e *Editor) SetMeta(m Meta) *Editor {
+	e.meta = &m
+	return e
+}
+
+// args gets the ffmpeg arguments to write all edits to output, excluding the
+// final output filename. metaFile is the ffmetadata file if SetMeta() was used.
+func (e *Editor) args(info ProbeFile, metaFile string) ([]string, error) {
+	rm := make(map[int]struct{})
+	for _, d := range e.drop {
+		found, err := info.Streams.SelectKind(d.kind, d.sel)
+		if err != nil {
+			return nil, err
+		}
+		// Removing "ALL" subtitles or audio tracks from a file without any
+		// is fine, so scripts can run it on every file.
+		if len(found) == 0 && d.kind != "" && d.sel == "ALL" {
+			continue
+		}
+		if len(found) == 0 {
+			kind := "streams"
+			if d.kind != "" {
+				kind = d.kind + " streams"
+			}
+			return nil, fmt.Errorf("no %s match %q", kind, d.sel)
+		}
+		for _, s := range found {
+			rm[s.Index] = struct{}{}
+		}
+	}
+	for _, k := range e.keep {
+		found, err := info.Streams.SelectKind("", k)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no streams match %q", k)
+		}
+		for _, s := range info.Streams {
+			if !found.Contains(s.Index) && slices.ContainsFunc(found, func(f Stream) bool { return f.CodecType == s.CodecType }) {
+				rm[s.Index] = struct{}{}
+			}
+		}
+	}
+
+	streams := info.Streams
+	if len(e.order) > 0 {
+		streams = make(Streams, 0, len(info.Streams))
+		for _, o := range e.order {
+			found, err := info.Streams.SelectKind("", o)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, fmt.Errorf("no streams match %q", o)
+			}
+			for _, s := range found {
+				if !streams.Contains(s.Index) {
+					streams = append(streams, s)
+				}
+			}
+		}
+		for _, s := range info.Streams {
+			if !streams.Contains(s.Index) {
+				streams = append(streams, s)
+			}
+		}
+	}
+
+	args := []string{"-y", "-i", e.input}
+	for _, s := range e.addSub {
+		args = append(args, "-i", s.file)
+	}
+	for _, s := range e.addAudio {
+		args = append(args, "-i", s.file)
+	}
+	if metaFile != "" {
+		args = append(args, "-i", metaFile)
+	}
+
+	var (
+		nSub, nAudio, nOut int
+		out                = make(map[int]int) // Input index → output index.
+	)
+	for _, s := range streams {
+		if _, ok := rm[s.Index]; ok || s.CodecType == "data" {
+			continue
+		}
+		args = append(args, "-map", "0:"+strconv.Itoa(s.Index))
+		out[s.Index] = nOut
+		nOut++
+		switch {
+		case s.Subtitle():
+			nSub++
+		case s.Audio():
+			nAudio++
+		}
+	}
+	args = append(args, "-c", "copy")
+
+	disp := make(map[int][]string)
+	for _, d := range e.disp {
+		found, err := info.Streams.SelectKind("", d.sel)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no streams match %q", d.sel)
+		}
+		for _, s := range info.Streams {
+			n, ok := out[s.Index]
+			switch {
+			case !ok:
+			case found.Contains(s.Index):
+				if d.only {
+					disp[n] = append(disp[n], "+"+d.disp)
+				} else {
+					disp[n] = append(disp[n], d.disp)
+				}
+			case d.only && slices.ContainsFunc(found, func(f Stream) bool { return f.CodecType == s.CodecType }):
+				disp[n] = append(disp[n], "-"+d.disp)
+			}
+		}
+	}
+	for _, n := range zmap.KeysOrdered(disp) {
+		args = append(args, "-disposition:"+strconv.Itoa(n), joinDisposition(disp[n]))
+	}
+
+	input := 1
+	if len(e.addSub) > 0 {
+		codec := "srt"
+		if info.Format.FormatName == "mov,mp4,m4a,3gp,3g2,mj2" {
+			codec = "mov_text"
+		}
+		for _, s := range e.addSub {
+			args = append(args, "-map", strconv.Itoa(input)+":s", "-c:s:"+strconv.Itoa(nSub), codec)
+			if s.lang != "" {
+				args = append(args, "-metadata:s:s:"+strconv.Itoa(nSub), "language="+s.lang)
+			}
+			if s.disp != "" {
+				args = append(args, "-disposition:"+strconv.Itoa(nOut), s.disp)
+			}
+			input++
+			nSub++
+			nOut++
+		}
+	}
+	// TODO: look into -shortest and -apad
+	for _, s := range e.addAudio {
+		args = append(args, "-map", strconv.Itoa(input)+":a")
+		if s.lang != "" {
+			args = append(args, "-metadata:s:a:"+strconv.Itoa(nAudio), "language="+s.lang)
+		}
+		if s.title != "" {
+			args = append(args, "-metadata:s:a:"+strconv.Itoa(nAudio), "title="+s.title)
+		}
+		if s.disp != "" {
+			args = append(args, "-disposition:"+strconv.Itoa(nOut), s.disp)
+		}
+		input++
+		nAudio++
+		nOut++
+	}
+
+	if metaFile != "" {
+		args = append(args,
+			"-map_chapters", strconv.Itoa(input),
+			"-map_metadata:g", strconv.Itoa(input),
+			"-movflags", "+use_metadata_tags")
+		if ts := tagStream(info); len(e.meta.Streams) > 0 || ts > -1 {
+			sa, err := e.streamMeta(info, streams, out, ts)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, sa...)
+		}
+	} else {
+		args = append(args, "-map_chapters", "0", "-map_metadata", "0")
+	}
+	return args, nil
+}
+
+// streamMeta gets the arguments to set the stream metadata from SetMeta(). The
+// file's tags are written to the stream tagStream if it's not -1.
+//
+// Mapping the metadata of any stream disables ffmpeg's default of copying it
+// for all streams, so this copies it explicitly for streams that aren't in the
+// list, and sets everything for streams that are.
+func (e *Editor) streamMeta(info ProbeFile, streams Streams, out map[int]int, tagStream int) ([]string, error) {
+	set := make(map[int]MetaStream)
+	for _, ms := range e.meta.Streams {
+		if !info.Streams.Contains(ms.Index) {
+			return nil, fmt.Errorf("no stream with index %d", ms.Index)
+		}
+		set[ms.Index] = ms
+	}
+
+	var args []string
+	for _, s := range streams {
+		n, ok := out[s.Index]
+		if !ok {
+			continue
+		}
+		spec := "s:" + strconv.Itoa(n)
+		ms, ok := set[s.Index]
+		if s.Index == tagStream {
+			for _, t := range [][2]string{{"title", e.meta.Title}, {"artist", e.meta.Artist}, {"date", e.meta.Date}} {
+				if t[1] != "" {
+					args = append(args, "-metadata:"+spec, t[0]+"="+t[1])
+				}
+			}
+			for _, k := range zmap.KeysOrdered(e.meta.Other) {
+				args = append(args, "-metadata:"+spec, k+"="+e.meta.Other[k])
+			}
+		} else if !ok {
+			args = append(args, "-map_metadata:"+spec, "0:s:"+strconv.Itoa(s.Index))
+			continue
+		}
+		if ms.Language != "" {
+			args = append(args, "-metadata:"+spec, "language="+ms.Language)
+		}
+		if ms.Title != "" {
+			args = append(args, "-metadata:"+spec, "title="+ms.Title)
+		}
+		for _, k := range zmap.KeysOrdered(ms.Tags) {
+			args = append(args, "-metadata:"+spec, k+"="+ms.Tags[k])
+		}
+	}
+	return args, nil
+}
+
+// joinDisposition joins a list of dispositions; an absolute disposition
+// replaces everything before it.
+func joinDisposition(d []string) string {
+	var j string
+	for _, dd := range d {
+		switch {
+		case dd == "":
+		case j == "" || (dd[0] != '+' && dd[0] != '-'):
+			j = dd
+		case j == "0" && dd[0] == '+':
+			j = dd[1:]
+		case j == "0":
+		default:
+			j += dd
+		}
+	}
+	return j
+}
+
+// Write all edits to output with a single ffmpeg invocation. The input file is
+// replaced if output is the same as input.
+//
+// Data streams, such as the chapter text track in MP4 audiobooks or the
+// timecode track from phone cameras, are not copied, as most muxers can't
+// write them.
+func (e *Editor) Write(ctx context.Context, output string) error {
+	info, err := Probe(ctx, e.input)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.op, err)
+	}
+
+	var metaFile string
+	if e.meta != nil {
+		m := *e.meta
+		m.Chapters = chapterEnds(m.Chapters, info.Format.Duration.Duration)
+		metaFile, err = writeTemp(ctx, "", "ffmeta-*.txt", m.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.op, err)
+		}
+		defer remove(ctx, metaFile)
+	}
+
+	args, err := e.args(info, metaFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.op, err)
+	}
+
+	inPlace := filepath.Clean(e.input) == filepath.Clean(output)
+	if inPlace {
+		output, err = tmpFile(ctx, e.input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.op, err)
+		}
+		defer remove(ctx, output)
+	}
+
+	_, err = ffmpegProgress(ctx, e.op, info.Format.Duration.Duration, append(args, output)...)
+	if err != nil {
+		return err
+	}
+	if inPlace {
+		err = rename(ctx, output, e.input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.op, err)
+		}
+	}
+	return nil
+}
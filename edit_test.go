@@ -0,0 +1,154 @@
+package wtff
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEditorArgs(t *testing.T) {
+	lang := func(l string) map[string]any { return map[string]any{"language": l} }
+	mkv := ProbeFile{Streams: Streams{
+		{Index: 0, CodecName: "h264", CodecType: "video"},
+		{Index: 1, CodecName: "aac", CodecType: "audio", Tags: lang("eng"), Disposition: Disposition{Default: 1}},
+		{Index: 2, CodecName: "ac3", CodecType: "audio", Tags: lang("jpn")},
+		{Index: 3, CodecName: "subrip", CodecType: "subtitle", Tags: lang("eng")},
+		{Index: 4, CodecName: "subrip", CodecType: "subtitle", Tags: lang("dut")},
+	}}
+	mkv.Format.FormatName = "matroska,webm"
+	mp4 := ProbeFile{Streams: mkv.Streams[:2]}
+	mp4.Format.FormatName = "mov,mp4,m4a,3gp,3g2,mj2"
+	ogg := ProbeFile{Streams: Streams{{Index: 0, CodecName: "vorbis", CodecType: "audio"}}}
+	ogg.Format.FormatName = "ogg"
+
+	tests := []struct {
+		name string
+		e    *Editor
+		info ProbeFile
+		want string
+	}{
+		{"nothing", Edit("in.mkv"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:1 -map 0:2 -map 0:3 -map 0:4 -c copy -map_chapters 0 -map_metadata 0"},
+
+		{"remove sub", Edit("in.mkv").RemoveSub("dut"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:1 -map 0:2 -map 0:3 -c copy -map_chapters 0 -map_metadata 0"},
+		{"remove all audio", Edit("in.mkv").RemoveAudio("ALL"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:3 -map 0:4 -c copy -map_chapters 0 -map_metadata 0"},
+		{"remove all subs without subs", Edit("in.mp4").RemoveSub("ALL"), mp4,
+			"-y -i in.mp4 -map 0:0 -map 0:1 -c copy -map_chapters 0 -map_metadata 0"},
+		{"drop", Edit("in.mkv").DropStreams("1,s:eng"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:2 -map 0:4 -c copy -map_chapters 0 -map_metadata 0"},
+		{"keep", Edit("in.mkv").KeepStreams("a:jpn"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:2 -map 0:3 -map 0:4 -c copy -map_chapters 0 -map_metadata 0"},
+
+		{"order", Edit("in.mkv").OrderStreams("a:jpn", "s:dut"), mkv,
+			"-y -i in.mkv -map 0:2 -map 0:4 -map 0:0 -map 0:1 -map 0:3 -c copy -map_chapters 0 -map_metadata 0"},
+		{"order duplicate", Edit("in.mkv").OrderStreams("s", "s:dut"), mkv,
+			"-y -i in.mkv -map 0:3 -map 0:4 -map 0:0 -map 0:1 -map 0:2 -c copy -map_chapters 0 -map_metadata 0"},
+
+		{"disposition", Edit("in.mkv").SetDisposition("s:dut", "forced"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:1 -map 0:2 -map 0:3 -map 0:4 -c copy -disposition:4 forced -map_chapters 0 -map_metadata 0"},
+		{"disposition combined", Edit("in.mkv").SetDisposition("s", "0").SetDisposition("s:dut", "+forced").SetDisposition("s:eng", "-forced"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:1 -map 0:2 -map 0:3 -map 0:4 -c copy -disposition:3 0 -disposition:4 forced -map_chapters 0 -map_metadata 0"},
+		{"default", Edit("in.mkv").SetDefault("a:jpn"), mkv,
+			"-y -i in.mkv -map 0:0 -map 0:1 -map 0:2 -map 0:3 -map 0:4 -c copy -disposition:1 -default -disposition:2 +default -map_chapters 0 -map_metadata 0"},
+
+		{"add sub", Edit("in.mkv").AddSub("en.srt", "eng", "default"), mkv,
+			"-y -i in.mkv -i en.srt -map 0:0 -map 0:1 -map 0:2 -map 0:3 -map 0:4 -c copy " +
+				"-map 1:s -c:s:2 srt -metadata:s:s:2 language=eng -disposition:5 default -map_chapters 0 -map_metadata 0"},
+		{"add sub mp4", Edit("in.mp4").AddSub("en.srt", ""), mp4,
+			"-y -i in.mp4 -i en.srt -map 0:0 -map 0:1 -c copy -map 1:s -c:s:0 mov_text -map_chapters 0 -map_metadata 0"},
+		{"add audio", Edit("in.mkv").AddAudio("c.opus", "eng", "Commentary", "comment"), mkv,
+			"-y -i in.mkv -i c.opus -map 0:0 -map 0:1 -map 0:2 -map 0:3 -map 0:4 -c copy " +
+				"-map 1:a -metadata:s:a:2 language=eng -metadata:s:a:2 title=Commentary -disposition:5 comment -map_chapters 0 -map_metadata 0"},
+		{"add both", Edit("in.mkv").AddAudio("c.opus", "", "").AddSub("nl.srt", "dut").RemoveAudio("jpn"), mkv,
+			"-y -i in.mkv -i nl.srt -i c.opus -map 0:0 -map 0:1 -map 0:3 -map 0:4 -c copy " +
+				"-map 1:s -c:s:2 srt -metadata:s:s:2 language=dut -map 2:a -map_chapters 0 -map_metadata 0"},
+
+		// Stream 1 is dropped, so the output indexes of everything after it
+		// shift by one.
+		{"remap", Edit("in.mkv").DropStreams("1").OrderStreams("s").SetDefault("s:dut").AddSub("en.srt", "eng", "forced"), mkv,
+			"-y -i in.mkv -i en.srt -map 0:3 -map 0:4 -map 0:0 -map 0:2 -c copy -disposition:0 -default -disposition:1 +default " +
+				"-map 1:s -c:s:2 srt -metadata:s:s:2 language=eng -disposition:4 forced -map_chapters 0 -map_metadata 0"},
+		{"remap meta", Edit("in.mkv").DropStreams("1").SetMeta(Meta{Streams: []MetaStream{{Index: 2, Language: "jpn", Title: "Japanese"}}}), mkv,
+			"-y -i in.mkv -i meta.txt -map 0:0 -map 0:2 -map 0:3 -map 0:4 -c copy -map_chapters 1 -map_metadata:g 1 -movflags +use_metadata_tags " +
+				"-map_metadata:s:0 0:s:0 -metadata:s:1 language=jpn -metadata:s:1 title=Japanese -map_metadata:s:2 0:s:3 -map_metadata:s:3 0:s:4"},
+		{"meta ogg", Edit("in.ogg").SetMeta(Meta{Title: "T", Artist: "A", Other: map[string]string{"genre": "Jazz"}}), ogg,
+			"-y -i in.ogg -i meta.txt -map 0:0 -c copy -map_chapters 1 -map_metadata:g 1 -movflags +use_metadata_tags " +
+				"-metadata:s:0 title=T -metadata:s:0 artist=A -metadata:s:0 genre=Jazz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metaFile string
+			if tt.e.meta != nil {
+				metaFile = "meta.txt"
+			}
+			args, err := tt.e.args(tt.info, metaFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := strings.Join(args, " "); have != tt.want {
+				t.Errorf("\nhave: %s\nwant: %s", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorArgsError(t *testing.T) {
+	info := ProbeFile{Streams: Streams{
+		{Index: 0, CodecName: "h264", CodecType: "video"},
+		{Index: 1, CodecName: "aac", CodecType: "audio", Tags: map[string]any{"language": "eng"}},
+	}}
+	tests := []struct {
+		e       *Editor
+		wantErr string
+	}{
+		{Edit("in.mkv").RemoveSub("eng"), `no subtitle streams match "eng"`},
+		{Edit("in.mkv").RemoveAudio("jpn"), `no audio streams match "jpn"`},
+		{Edit("in.mkv").DropStreams("5"), `no streams match "5"`},
+		{Edit("in.mkv").KeepStreams("a:jpn"), `no streams match "a:jpn"`},
+		{Edit("in.mkv").OrderStreams("s"), `no streams match "s"`},
+		{Edit("in.mkv").SetDisposition("s", "forced"), `no streams match "s"`},
+		{Edit("in.mkv").DropStreams("a,,s"), "empty condition"},
+		{Edit("in.mkv").SetMeta(Meta{Streams: []MetaStream{{Index: 5}}}), "no stream with index 5"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := tt.e.args(info, "meta.txt")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteMetaDataStreams(t *testing.T) {
+	r := &FakeRunner{Output: func(prog string, args []string) (string, string, error) {
+		if prog == "ffprobe" {
+			return `{"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"60.000000"},"streams":[` +
+				`{"index":0,"codec_name":"aac","codec_type":"audio"},` +
+				`{"index":1,"codec_name":"bin_data","codec_type":"data"},` +
+				`{"index":2,"codec_name":"mjpeg","codec_type":"video","disposition":{"attached_pic":1}},` +
+				`{"index":3,"codec_name":"none","codec_tag_string":"tmcd","codec_type":"data"}]}`, "", nil
+		}
+		return fakeOutput(prog, args), "", nil
+	}}
+	ctx, plan := DryRun(WithRunner(context.Background(), r))
+
+	m := Meta{Title: "Book", Streams: []MetaStream{{Index: 2, Title: "Cover"}}}
+	err := WriteMeta(ctx, m, "book.m4b", "out.m4b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := plan.String()
+	if !strings.Contains(p, " -map 0:0 -map 0:2 -c copy ") {
+		t.Errorf("wrong streams:\n%s", p)
+	}
+	if strings.Contains(p, "0:1") || strings.Contains(p, "0:3") {
+		t.Errorf("data streams copied:\n%s", p)
+	}
+	if !strings.Contains(p, "-metadata:s:1 title=Cover") {
+		t.Errorf("stream metadata not remapped:\n%s", p)
+	}
+}
@@ -0,0 +1,118 @@
+package wtff
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+
+	"zgo.at/zstd/zstring"
+)
+
+// Errors that an FFError can match with errors.Is(), in addition to
+// fs.ErrNotExist and fs.ErrPermission.
+var (
+	ErrDiskFull    = errors.New("disk full")
+	ErrUnsupported = errors.New("codec not supported in container")
+	ErrInvalidData = errors.New("invalid data in input")
+)
+
+// FFError is returned when ffmpeg or ffprobe fails.
+//
+// This can be matched with errors.Is() against fs.ErrNotExist,
+// fs.ErrPermission, ErrDiskFull, ErrUnsupported, and ErrInvalidData to get a
+// rough idea of what went wrong.
+type FFError struct {
+	Op       string   // Operation, e.g. "wtff.Cut".
+	Prog     string   // "ffmpeg" or "ffprobe".
+	Args     []string // Full argument list.
+	ExitCode int      // Exit code; -1 if the process didn't run or exit normally.
+	Code     int      // AVERROR code as reported by ffprobe's -show_error; 0 if unknown.
+	Stderr   string   // Full stderr output.
+	Warnings []string // Warning lines from stderr.
+	Errors   []string // Error lines from stderr.
+	Err      error    // Underlying error from the Runner.
+}
+
+func newFFError(op, prog string, args []string, stderr string, err error) *FFError {
+	e := &FFError{Op: op, Prog: prog, Args: args, Stderr: stderr, Err: err, ExitCode: -1}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	}
+	for _, l := range strings.Split(stderr, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		// With "-v level+..." ffmpeg prefixes lines with the level; lines
+		// without the prefix are treated as errors.
+		switch {
+		case strings.Contains(l, "[warning] "):
+			e.Warnings = append(e.Warnings, strings.Replace(l, "[warning] ", "", 1))
+		case strings.Contains(l, "[info] "), strings.Contains(l, "[verbose] "), strings.Contains(l, "[debug] "):
+		default:
+			for _, lvl := range []string{"[error] ", "[fatal] ", "[panic] "} {
+				l = strings.Replace(l, lvl, "", 1)
+			}
+			e.Errors = append(e.Errors, l)
+		}
+	}
+	return e
+}
+
+// AVERROR codes, which are negated errno values or FFERRTAG() values.
+const (
+	averrorNotExist    = -2
+	averrorPermission  = -13
+	averrorDiskFull    = -28
+	averrorInvalidData = -0x41444e49 // FFERRTAG('I','N','D','A')
+)
+
+func (e *FFError) Is(target error) bool {
+	var (
+		msg   = strings.ToLower(e.Stderr + "\n" + strings.Join(e.Errors, "\n"))
+		has   = func(s ...string) bool { return containsAny(msg, s...) }
+		match bool
+	)
+	switch target {
+	case fs.ErrNotExist:
+		match = e.Code == averrorNotExist || has("no such file or directory")
+	case fs.ErrPermission:
+		match = e.Code == averrorPermission || has("permission denied")
+	case ErrDiskFull:
+		match = e.Code == averrorDiskFull || has("no space left on device")
+	case ErrInvalidData:
+		match = e.Code == averrorInvalidData || has("invalid data found when processing input")
+	case ErrUnsupported:
+		match = has("not currently supported in container", "could not find tag for codec",
+			"codec not supported", "unsupported codec")
+	}
+	return match
+}
+
+func (e *FFError) Unwrap() error { return e.Err }
+
+func (e *FFError) Error() string {
+	var msg string
+	switch {
+	case len(e.Errors) > 0:
+		msg = strings.Join(e.Errors, "; ")
+	case e.Stderr != "":
+		msg = zstring.ElideLeft(strings.TrimSpace(e.Stderr), 500)
+	}
+	if msg == "" {
+		return fmt.Sprintf("%s: %s: %s", e.Op, e.Prog, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", e.Op, e.Prog, e.Err, msg)
+}
+
+func containsAny(s string, find ...string) bool {
+	for _, f := range find {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package wtff
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"zgo.at/zstd/zmap"
+)
+
+// The ffmetadata format, as described in [ffmpeg-formats(1)]:
+//
+//   - The file starts with ";FFMETADATA1".
+//   - Lines starting with ";" or "#" are comments.
+//   - Metadata is as key=value; the special characters "=", ";", "#", "\", and
+//     newlines in both keys and values are escaped with a "\". We also escape
+//     carriage returns, as they're otherwise lost in "\r\n".
+//   - Metadata after a "[STREAM]" or "[CHAPTER]" line applies to that stream or
+//     chapter. Streams are counted in order; chapters start with TIMEBASE,
+//     START, and END.
+//
+// [ffmpeg-formats(1)]: https://ffmpeg.org/ffmpeg-formats.html#Metadata-2
+
+var ffmetaEscape = strings.NewReplacer(`\`, `\\`, `=`, `\=`, `;`, `\;`, `#`, `\#`, "\n", "\\\n", "\r", "\\\r")
+
+type ffmetaLine struct {
+	n        int // Line number.
+	key, val string
+	eq       bool // Has an unescaped "=".
+	comment  bool
+}
+
+// splitFFMeta splits the ffmetadata in to lines, splitting key=value and
+// removing escapes. Escaped newlines are part of the line they're in.
+func splitFFMeta(s string) []ffmetaLine {
+	var (
+		lines []ffmetaLine
+		cur   = ffmetaLine{n: 1}
+		b     = new(strings.Builder)
+		n     = 1
+		start = true
+	)
+	flush := func() {
+		if cur.eq {
+			cur.val = b.String()
+		} else {
+			cur.key = b.String()
+		}
+		if cur.eq || cur.comment || cur.key != "" {
+			lines = append(lines, cur)
+		}
+		b.Reset()
+		cur, start = ffmetaLine{n: n}, true
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if start && (c == ';' || c == '#') {
+			cur.comment = true
+		}
+		start = false
+		switch {
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] == '\n' {
+					n++
+				}
+				b.WriteByte(s[i])
+			}
+		case c == '=' && !cur.eq:
+			cur.key, cur.eq = b.String(), true
+			b.Reset()
+		case c == '\n':
+			n++
+			flush()
+		case c == '\r' && i+1 < len(s) && s[i+1] == '\n':
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return lines
+}
+
+// ParseMeta parses metadata in the ffmetadata format.
+func ParseMeta(input string) (Meta, error) {
+	m := Meta{Other: make(map[string]string)}
+
+	if !strings.HasPrefix(input, ";FFMETADATA") {
+		first, _, _ := strings.Cut(input, "\n")
+		return m, fmt.Errorf("wtff.ParseMeta: unexpected start: %q", first)
+	}
+
+	var (
+		chapter = -1
+		stream  = -1
+	)
+	for _, l := range splitFFMeta(input) {
+		if l.comment {
+			continue
+		}
+		if !l.eq {
+			switch l.key {
+			case "[STREAM]":
+				m.Streams = append(m.Streams, MetaStream{Index: len(m.Streams)})
+				chapter, stream = -1, len(m.Streams)-1
+			case "[CHAPTER]":
+				m.Chapters = append(m.Chapters, MetaChapter{})
+				chapter, stream = len(m.Chapters)-1, -1
+			default:
+				return m, fmt.Errorf("wtff.ParseMeta: line %d: %q", l.n, l.key)
+			}
+			continue
+		}
+
+		switch {
+		case chapter > -1:
+			c := &m.Chapters[chapter]
+			var err error
+			switch strings.ToLower(l.key) {
+			case "timebase":
+				a, b, ok := strings.Cut(l.val, "/")
+				if !ok {
+					return m, fmt.Errorf("wtff.ParseMeta: line %d: invalid timebase: %q", l.n, l.val)
+				}
+				c.Timebase[0], err = strconv.ParseInt(a, 10, 64)
+				if err == nil {
+					c.Timebase[1], err = strconv.ParseInt(b, 10, 64)
+				}
+			case "start":
+				c.Start, err = strconv.ParseInt(l.val, 10, 64)
+			case "end":
+				c.End, err = strconv.ParseInt(l.val, 10, 64)
+			case "title":
+				c.Title = l.val
+			}
+			if err != nil {
+				return m, fmt.Errorf("wtff.ParseMeta: line %d: %v", l.n, err)
+			}
+		case stream > -1:
+			s := &m.Streams[stream]
+			switch strings.ToLower(l.key) {
+			case "language":
+				s.Language = l.val
+			case "title":
+				s.Title = l.val
+			default:
+				if s.Tags == nil {
+					s.Tags = make(map[string]string)
+				}
+				s.Tags[l.key] = l.val
+			}
+		default:
+			m.set(l.key, l.val)
+		}
+	}
+	return m, nil
+}
+
+// String gets the metadata in the ffmetadata format.
+//
+// The streams are written in order of the index, with empty "[STREAM]"
+// sections for any streams that aren't in the list.
+func (m Meta) String() string {
+	b := new(strings.Builder)
+	b.WriteString(";FFMETADATA1\n")
+	kv := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(b, "%s=%s\n", ffmetaEscape.Replace(k), ffmetaEscape.Replace(v))
+		}
+	}
+
+	kv("major_brand", m.MajorBrand)
+	kv("minor_version", m.MinorVersion)
+	kv("compatible_brands", m.CompatibleBrands)
+	kv("encoder", m.Encoder)
+	kv("title", m.Title)
+	kv("artist", m.Artist)
+	kv("date", m.Date)
+	for _, k := range zmap.KeysOrdered(m.Other) {
+		kv(k, m.Other[k])
+	}
+
+	streams := slices.Clone(m.Streams)
+	slices.SortFunc(streams, func(a, b MetaStream) int { return a.Index - b.Index })
+	n := 0
+	for _, s := range streams {
+		for ; n <= s.Index; n++ {
+			b.WriteString("[STREAM]\n")
+		}
+		kv("language", s.Language)
+		kv("title", s.Title)
+		for _, k := range zmap.KeysOrdered(s.Tags) {
+			kv(k, s.Tags[k])
+		}
+	}
+
+	for _, c := range m.Chapters {
+		b.WriteString("[CHAPTER]\n")
+		fmt.Fprintf(b, "TIMEBASE=%d/%d\n", c.Timebase[0], c.Timebase[1])
+		fmt.Fprintf(b, "START=%d\n", c.Start)
+		fmt.Fprintf(b, "END=%d\n", c.End)
+		kv("title", c.Title)
+	}
+	return b.String()
+}
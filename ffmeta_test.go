@@ -0,0 +1,168 @@
+package wtff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMeta(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Meta
+	}{
+		{"empty", ";FFMETADATA1\n", Meta{}},
+		{"global", ";FFMETADATA1\ntitle=A title\nartist=Someone\ndate=2024\ngenre=Jazz\n", Meta{
+			Title: "A title", Artist: "Someone", Date: "2024",
+			Other: map[string]string{"genre": "Jazz"},
+		}},
+		{"case", ";FFMETADATA1\nTITLE=x\nArtist=y\n", Meta{Title: "x", Artist: "y"}},
+		{"brand", ";FFMETADATA1\nmajor_brand=isom\nminor_version=512\ncompatible_brands=isomiso2\nencoder=Lavf\n", Meta{
+			MajorBrand: "isom", MinorVersion: "512", CompatibleBrands: "isomiso2", Encoder: "Lavf",
+		}},
+		{"special", ";FFMETADATA1\n" + `title=a\=b\;c\#d\\e` + "\n" + `k\=ey=v` + "\n", Meta{
+			Title: `a=b;c#d\e`,
+			Other: map[string]string{"k=ey": "v"},
+		}},
+		{"unescaped =", ";FFMETADATA1\ntitle=a=b\n", Meta{Title: "a=b"}},
+		{"escaped newline", ";FFMETADATA1\ncomment=line 1\\\nline 2\\\n\nartist=x\n", Meta{
+			Artist: "x",
+			Other:  map[string]string{"comment": "line 1\nline 2\n"},
+		}},
+		{"crlf", ";FFMETADATA1\r\ntitle=x\r\nartist=y\r\n", Meta{Title: "x", Artist: "y"}},
+		{"escaped cr", ";FFMETADATA1\ntitle=a\\\rb\n", Meta{Title: "a\rb"}},
+		{"comments", ";FFMETADATA1\n; comment\n# title=no\ntitle=yes\n\n#\n", Meta{Title: "yes"}},
+		{"streams", ";FFMETADATA1\ntitle=t\n[STREAM]\n[STREAM]\nlanguage=eng\ntitle=Commentary\nhandler_name=x\n[STREAM]\nLANGUAGE=dut\n", Meta{
+			Title: "t",
+			Streams: []MetaStream{
+				{Index: 0},
+				{Index: 1, Language: "eng", Title: "Commentary", Tags: map[string]string{"handler_name": "x"}},
+				{Index: 2, Language: "dut"},
+			},
+		}},
+		{"chapters", ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1500\ntitle=One\n[CHAPTER]\ntimebase=1/44100\nstart=66150\nend=132300\ntitle=Two \\= 2\n", Meta{
+			Chapters: []MetaChapter{
+				{Timebase: [2]int64{1, 1000}, Start: 0, End: 1500, Title: "One"},
+				{Timebase: [2]int64{1, 44100}, Start: 66150, End: 132300, Title: "Two = 2"},
+			},
+		}},
+		{"streams and chapters", ";FFMETADATA1\n[STREAM]\ntitle=s\n[CHAPTER]\nTIMEBASE=1/1\nSTART=1\nEND=2\n[STREAM]\nlanguage=fra\n", Meta{
+			Streams: []MetaStream{
+				{Index: 0, Title: "s"},
+				{Index: 1, Language: "fra"},
+			},
+			Chapters: []MetaChapter{{Timebase: [2]int64{1, 1}, Start: 1, End: 2}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := ParseMeta(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(normalMeta(have), normalMeta(tt.want)) {
+				t.Errorf("\nhave: %#v\nwant: %#v", have, tt.want)
+			}
+
+			s := tt.want.String()
+			have, err = ParseMeta(s)
+			if err != nil {
+				t.Fatalf("round-trip: %s\n%s", err, s)
+			}
+			if !reflect.DeepEqual(normalMeta(have), normalMeta(tt.want)) {
+				t.Errorf("round-trip:\nhave: %#v\nwant: %#v\n%s", have, tt.want, s)
+			}
+		})
+	}
+}
+
+func TestParseMetaError(t *testing.T) {
+	tests := []struct {
+		in, wantErr string
+	}{
+		{"", "unexpected start"},
+		{"title=x\n", "unexpected start"},
+		{";FFMETADATA1\nnokey\n", `line 2: "nokey"`},
+		{";FFMETADATA1\n[STREAMS]\n", `line 2: "[STREAMS]"`},
+		{";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1000\n", "line 3: invalid timebase"},
+		{";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/x\n", "line 3:"},
+		{";FFMETADATA1\ncomment=a\\\nb\n[CHAPTER]\nSTART=x\n", "line 5:"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseMeta(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetaString(t *testing.T) {
+	m := Meta{
+		Title:   "a=b",
+		Other:   map[string]string{"comment": "x\ny", "empty": ""},
+		Streams: []MetaStream{{Index: 2, Language: "eng"}},
+		Chapters: []MetaChapter{
+			{Timebase: [2]int64{1, 1000}, Start: 0, End: 1000, Title: "#1"},
+		},
+	}
+	want := ";FFMETADATA1\n" +
+		"title=a\\=b\n" +
+		"comment=x\\\ny\n" +
+		"[STREAM]\n[STREAM]\n[STREAM]\nlanguage=eng\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1000\ntitle=\\#1\n"
+	if have := m.String(); have != want {
+		t.Errorf("\nhave:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+func FuzzParseMeta(f *testing.F) {
+	f.Add(";FFMETADATA1\ntitle=x\n")
+	f.Add(";FFMETADATA1\r\n" + `title=a\=b\;c\#d\\e` + "\r\nk\\\nx=v\\\r\n#c\n;c\n")
+	f.Add(";FFMETADATA1\n[STREAM]\n[STREAM]\nlanguage=eng\nx=y\n")
+	f.Add(";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1500\ntitle=One\n")
+	f.Fuzz(func(t *testing.T, in string) {
+		m, err := ParseMeta(in)
+		if err != nil {
+			return
+		}
+		s := m.String()
+		have, err := ParseMeta(s)
+		if err != nil {
+			t.Fatalf("%s\ninput:\n%q\noutput:\n%q", err, in, s)
+		}
+		if !reflect.DeepEqual(normalMeta(have), normalMeta(m)) {
+			t.Fatalf("\nhave: %#v\nwant: %#v\ninput:\n%q\noutput:\n%q", have, m, in, s)
+		}
+	})
+}
+
+// normalMeta removes the empty values, which aren't written by Meta.String(),
+// and sets empty maps to nil.
+func normalMeta(m Meta) Meta {
+	clean := func(tags map[string]string) map[string]string {
+		c := make(map[string]string, len(tags))
+		for k, v := range tags {
+			if v != "" {
+				c[k] = v
+			}
+		}
+		if len(c) == 0 {
+			return nil
+		}
+		return c
+	}
+	m.Other = clean(m.Other)
+	if len(m.Streams) > 0 {
+		streams := make([]MetaStream, 0, len(m.Streams))
+		for _, s := range m.Streams {
+			s.Tags = clean(s.Tags)
+			streams = append(streams, s)
+		}
+		m.Streams = streams
+	}
+	return m
+}
@@ -5,13 +5,12 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
-	"zgo.at/zstd/zbyte"
 	"zgo.at/zstd/zmap"
 )
 
@@ -19,20 +18,6 @@ import (
 // originally came from two different scripts and got merged here, but it's kind
 // of redundant.
 
-// TODO: doesn't seem to work for ogg/opus files? ffmetadata is just blank.
-// Instead of using ffmetadata, get it with:
-//
-// ffprobe -show_streams
-//   format.tags (mp4, mp3, flac)
-//   streams[0].tags (ogg, opus)
-//
-// And write with:
-//   ffmpeg -i input.mp4 -c copy
-//     -metadata title="..."
-//     -metadata artist="..."
-//     -metadata other="..."
-//     output.mp4
-
 type (
 	Meta struct {
 		Comment          string            `toml:"-"`
@@ -45,6 +30,16 @@ type (
 		Date             string            `toml:"date"`
 		Chapters         []MetaChapter     `toml:"chapters"`
 		Other            map[string]string `toml:"other"`
+		Streams          []MetaStream      `toml:"streams"`
+		Duration         time.Duration     `toml:"-"` // File duration, if known.
+	}
+	// MetaStream is the metadata for a single stream.
+	MetaStream struct {
+		Index    int               `toml:"index"`
+		Codec    string            `toml:"-"` // Only informational: "audio aac".
+		Language string            `toml:"language"`
+		Title    string            `toml:"title"`
+		Tags     map[string]string `toml:"tags"`
 	}
 	MetaChapter struct {
 		Timebase  [2]int64 `toml:"-"`
@@ -52,6 +47,7 @@ type (
 		End       int64    `toml:"-"`
 		Title     string   `toml:"title"`
 		TOMLStart string   `toml:"start"`
+		TOMLEnd   string   `toml:"end"`
 	}
 )
 
@@ -64,258 +60,259 @@ func (m Meta) IsZeroExceptChapters() bool {
 }
 
 func (m MetaChapter) StartSecs() int {
-	return int(time.Duration(m.Start * (1_000_000_000 / m.Timebase[1])).Seconds())
+	return int(m.StartTime().Seconds())
+}
+
+// StartTime gets the chapter start, rounded to milliseconds.
+func (m MetaChapter) StartTime() time.Duration { return m.toDuration(m.Start) }
+
+// EndTime gets the chapter end, rounded to milliseconds.
+func (m MetaChapter) EndTime() time.Duration { return m.toDuration(m.End) }
+
+func (m MetaChapter) toDuration(t int64) time.Duration {
+	tb := m.Timebase
+	if tb[0] == 0 || tb[1] == 0 {
+		tb = [2]int64{1, 1_000_000_000} // ffmpeg default.
+	}
+	return time.Duration(float64(t) * float64(tb[0]) / float64(tb[1]) * float64(time.Second)).Round(time.Millisecond)
 }
 
+// ReadMeta reads the metadata, chapters, and stream metadata from input.
+//
+// For Ogg files the tags are read from the first audio stream, as that's where
+// they're stored.
 func ReadMeta(ctx context.Context, input string) (Meta, error) {
-	out, err := ffmpeg(ctx,
-		"-v", "error",
+	// Always run, even in dry-run mode.
+	out, err := run(ctx, "wtff.ReadMeta", nil, "ffmpeg",
+		"-hide_banner", "-v", "level+error",
 		"-i", input,
-		"-f", "ffmetadata", "-").CombinedOutput()
+		"-f", "ffmetadata", "-")
 	if err != nil {
-		return Meta{}, fmt.Errorf("wtff.ReadMeta: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return Meta{}, err
 	}
 	m, err := ParseMeta(string(out))
+	if err != nil {
+		return m, err
+	}
 	m.Comment = input
-	return m, err
+
+	info, err := Probe(ctx, input)
+	if err != nil {
+		return m, err
+	}
+	m.Duration = info.Format.Duration.Duration
+	m.Streams = nil
+	ts := tagStream(info)
+	for _, s := range info.Streams {
+		if s.Index == ts {
+			for k, v := range s.Tags {
+				m.set(k, fmt.Sprint(v))
+			}
+			continue
+		}
+		ms := MetaStream{Index: s.Index, Codec: s.CodecType + " " + s.CodecName}
+		for k, v := range s.Tags {
+			vv := fmt.Sprint(v)
+			switch {
+			case k == "language":
+				ms.Language = vv
+			case k == "title":
+				ms.Title = vv
+			case isStatTag(k):
+			default:
+				if ms.Tags == nil {
+					ms.Tags = make(map[string]string)
+				}
+				ms.Tags[k] = vv
+			}
+		}
+		m.Streams = append(m.Streams, ms)
+	}
+	return m, nil
+}
+
+// isStatTag reports if this is one of the statistics tags mkvmerge adds (e.g.
+// "BPS-eng", "_STATISTICS_WRITING_APP"); these are regenerated on write and
+// aren't useful to edit.
+func isStatTag(k string) bool {
+	k, _, _ = strings.Cut(k, "-")
+	switch k {
+	case "BPS", "DURATION", "NUMBER_OF_FRAMES", "NUMBER_OF_BYTES":
+		return true
+	}
+	return strings.HasPrefix(k, "_STATISTICS_")
 }
 
-func ParseMetaFromTOML(input string) (Meta, error) {
+// ParseMetaFromTOML parses metadata in the format from Meta.TOML().
+//
+// The duration is the duration of the file the metadata is for, which is used
+// as the end of the last chapter if it doesn't have an explicit end. The end is
+// left at 0 if duration is 0, in which case Editor.Write() and WriteMeta() will
+// set it from the file.
+func ParseMetaFromTOML(input string, duration time.Duration) (Meta, error) {
 	var m Meta
 	_, err := toml.Decode(input, &m)
 	if err != nil {
 		return m, err
 	}
+	m.Duration = duration
+
+	seen := make(map[int]struct{})
+	for _, s := range m.Streams {
+		if s.Index < 0 {
+			return m, fmt.Errorf("invalid stream index: %d", s.Index)
+		}
+		if _, ok := seen[s.Index]; ok {
+			return m, fmt.Errorf("stream %d appears more than once", s.Index)
+		}
+		seen[s.Index] = struct{}{}
+	}
 
 	for i, c := range m.Chapters {
 		m.Chapters[i].Timebase = [2]int64{1, 1000}
-		sp := strings.Split(c.TOMLStart, ":")
-		switch len(sp) {
-		case 2:
-			a, err := strconv.Atoi(sp[0])
-			if err != nil {
-				return m, err
-			}
-			b, err := strconv.Atoi(sp[1])
-			if err != nil {
-				return m, err
-			}
-			m.Chapters[i].Start = int64(a*60+b) * 1000
-		case 3:
-			a, err := strconv.Atoi(sp[0])
-			if err != nil {
-				return m, err
-			}
-			b, err := strconv.Atoi(sp[1])
-			if err != nil {
-				return m, err
-			}
-			c, err := strconv.Atoi(sp[2])
+		m.Chapters[i].Start, err = parseChapterTime(c.TOMLStart)
+		if err != nil {
+			return m, fmt.Errorf("chapter %d (%q): invalid start: %w", i+1, c.Title, err)
+		}
+		if c.TOMLEnd != "" {
+			m.Chapters[i].End, err = parseChapterTime(c.TOMLEnd)
 			if err != nil {
-				return m, err
+				return m, fmt.Errorf("chapter %d (%q): invalid end: %w", i+1, c.Title, err)
 			}
-			m.Chapters[i].Start = int64(a*3600+b*60+c) * 1000
-		default:
-			return m, fmt.Errorf("invalid start: %q", c)
 		}
 		if i > 0 && m.Chapters[i-1].Start > m.Chapters[i].Start {
 			return m, fmt.Errorf("chapter %d (%q) starts after the preceding", i+1, c.Title)
 		}
+		if duration > 0 && m.Chapters[i].StartTime() >= duration {
+			return m, fmt.Errorf("chapter %d (%q) starts after the end of the file (%s)", i+1, c.Title, Time{Duration: duration})
+		}
 	}
 
 	// End *needs* to be set, even if it's just the next chapter. Weird Shit™
 	// happens if you don't.
-	for i := range m.Chapters {
-		if i == len(m.Chapters)-1 {
-			// Should be to end of file, but we don't have that here. This seems
-			// to work well.
-			//
-			// TODO: this fails on mkv files:
-			//   [matroska @ 0x56456d3ac380] Invalid chapter start (6228000000000) or end (-9223372036854775808)
-			// Should pass input file and get length.
-			m.Chapters[i].End = math.MaxInt64
-		} else {
-			m.Chapters[i].End = m.Chapters[i+1].Start
+	m.Chapters = chapterEnds(m.Chapters, duration)
+	for i, c := range m.Chapters {
+		if c.End > 0 && c.End <= c.Start {
+			return m, fmt.Errorf("chapter %d (%q) ends before it starts", i+1, c.Title)
 		}
 	}
-
-	return m, err
+	return m, nil
 }
 
-func ParseMeta(input string) (Meta, error) {
-	m := Meta{Other: make(map[string]string)}
-
-	lines := strings.Split(input, "\n")
-	if lines[0] != ";FFMETADATA1" {
-		return m, fmt.Errorf("wtff.ParseMeta: unexpected start: %q", lines[0])
-	}
-
-	var (
-		chapters bool
-		chapter  MetaChapter
-		skip     int
-	)
-	for i, line := range lines[1:] {
-		if skip > 0 {
-			skip--
-			continue
-		}
-		if line == "Output file is empty, nothing was encoded " || line == "" {
-			continue
-		}
-		if strings.ToLower(line) == "[chapter]" {
-			if chapter.Title != "" {
-				m.Chapters = append(m.Chapters, chapter)
-				chapter = MetaChapter{}
-			}
-			chapters = true
+// chapterEnds sets the end of all chapters without one to the start of the next
+// chapter, or duration for the last chapter. It returns a copy if anything was
+// changed.
+func chapterEnds(chapters []MetaChapter, duration time.Duration) []MetaChapter {
+	var cp []MetaChapter
+	for i, c := range chapters {
+		if c.End != 0 {
 			continue
 		}
-		k, v, ok := strings.Cut(line, "=")
-		if !ok {
-			return m, fmt.Errorf("wtff.ParseMeta: line %d: %q", i+1, line)
+		if cp == nil {
+			cp = slices.Clone(chapters)
 		}
-
-		var err error
-		if chapters {
-			switch strings.ToLower(k) {
-			case "timebase":
-				a, b, ok := strings.Cut(v, "/")
-				if !ok {
-					return m, fmt.Errorf("wtff.ParseMeta: line %d: invalid timebase: %q", i+1, v)
-				}
-				aa, err := strconv.ParseInt(a, 10, 64)
-				if err != nil {
-					return m, fmt.Errorf("wtff.ParseMeta: line %d: %v", i+1, err)
-				}
-				bb, err := strconv.ParseInt(b, 10, 64)
-				if err != nil {
-					return m, fmt.Errorf("wtff.ParseMeta: line %d: %v", i+1, err)
-				}
-
-				chapter.Timebase = [2]int64{aa, bb}
-			case "start":
-				chapter.Start, err = strconv.ParseInt(v, 10, 64)
-				if err != nil {
-					return m, fmt.Errorf("wtff.ParseMeta: line %d: %v", i+1, err)
-				}
-			case "end":
-				chapter.End, err = strconv.ParseInt(v, 10, 64)
-				if err != nil {
-					return m, fmt.Errorf("wtff.ParseMeta: line %d: %v", i+1, err)
-				}
-			case "title":
-				chapter.Title = v
+		if i == len(chapters)-1 {
+			tb := c.Timebase
+			if tb[0] == 0 || tb[1] == 0 {
+				tb = [2]int64{1, 1_000_000_000}
 			}
-			continue
-		}
-
-		if strings.HasSuffix(v, `\`) {
-			for {
-				line = lines[i+skip+1]
-				if skip > 0 {
-					v += "\n" + line
-				}
-				if !strings.HasSuffix(line, `\`) {
-					break
-				}
-				v = v[:len(v)-1]
-				skip++
-			}
-		}
-
-		switch strings.ToLower(k) {
-		case "major_brand":
-			m.MajorBrand = v
-		case "minor_version":
-			m.MinorVersion = v
-		case "compatible_brands":
-			m.CompatibleBrands = v
-		case "title":
-			m.Title = v
-		case "artist":
-			m.Artist = v
-		case "date":
-			m.Date = v
-		case "encoder":
-			m.Encoder = v
-		default:
-			m.Other[k] = v
+			cp[i].End = int64(math.Round(duration.Seconds() * float64(tb[1]) / float64(tb[0])))
+		} else {
+			cp[i].End = chapters[i+1].Start
 		}
 	}
-	if chapter.Title != "" {
-		m.Chapters = append(m.Chapters, chapter)
+	if cp == nil {
+		return chapters
 	}
-	return m, nil
+	return cp
 }
 
-func WriteMeta(ctx context.Context, m Meta, input, output string) error {
-	tmp, err := os.CreateTemp("", "ffmeta-*.txt")
-	if err != nil {
-		return fmt.Errorf("wtff.WriteMeta: %w", err)
-	}
-	defer os.Remove(tmp.Name())
-
-	_, err = tmp.WriteString(m.String())
-	if err != nil {
-		return fmt.Errorf("wtff.WriteMeta: %w", err)
+// parseChapterTime parses "[HH:]MM:SS[.fff]" to milliseconds.
+func parseChapterTime(t string) (int64, error) {
+	sp := strings.Split(t, ":")
+	if len(sp) < 2 || len(sp) > 3 {
+		return 0, fmt.Errorf("%q: not in the format [HH:]MM:SS[.fff]", t)
 	}
-	err = tmp.Close()
-	if err != nil {
-		return fmt.Errorf("wtff.WriteMeta: %w", err)
+	var hm int64
+	for _, p := range sp[:len(sp)-1] {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q: not in the format [HH:]MM:SS[.fff]", t)
+		}
+		hm = hm*60 + n
 	}
-	out, err := ffmpeg(ctx,
-		"-y",
-		"-i", input,
-		"-i", tmp.Name(),
-		"-map_chapters", "1",
-		"-map_metadata", "1",
-		"-codec", "copy",
-		"-movflags", "+use_metadata_tags", // Magic flag to make freeform MP4 tags work
-		output).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("wtff.WriteMeta: %w: %s", err, zbyte.ElideLeft(out, 500))
+	secs, err := time.ParseDuration(sp[len(sp)-1] + "s")
+	if err != nil || secs < 0 {
+		return 0, fmt.Errorf("%q: not in the format [HH:]MM:SS[.fff]", t)
 	}
-	return nil
+	return hm*60_000 + secs.Round(time.Millisecond).Milliseconds(), nil
 }
 
-func (m Meta) String() string {
-	b := new(strings.Builder)
-	b.WriteString(";FFMETADATA1\n")
-
-	if m.MajorBrand != "" {
-		fmt.Fprintf(b, "major_brand=%s\n", m.MajorBrand)
+// fmtChapterTime formats a chapter time as "MM:SS" or "HH:MM:SS", with
+// milliseconds if they're not 0.
+func fmtChapterTime(d time.Duration, hours bool) string {
+	ms := d.Milliseconds()
+	t := fmt.Sprintf("%02d:%02d", ms/60_000%60, ms/1000%60)
+	if hours {
+		t = fmt.Sprintf("%02d:%s", ms/3_600_000, t)
 	}
-	if m.MinorVersion != "" {
-		fmt.Fprintf(b, "minor_version=%s\n", m.MinorVersion)
+	if ms%1000 > 0 {
+		t += fmt.Sprintf(".%03d", ms%1000)
 	}
-	if m.CompatibleBrands != "" {
-		fmt.Fprintf(b, "compatible_brands=%s\n", m.CompatibleBrands)
-	}
-	if m.Encoder != "" {
-		fmt.Fprintf(b, "encoder=%s\n", m.Encoder)
-	}
-	if m.Title != "" {
-		fmt.Fprintf(b, "title=%s\n", strings.ReplaceAll(m.Title, "\n", "\\\n"))
-	}
-	if m.Artist != "" {
-		fmt.Fprintf(b, "artist=%s\n", strings.ReplaceAll(m.Artist, "\n", "\\\n"))
+	return t
+}
+
+func (m *Meta) set(k, v string) {
+	switch strings.ToLower(k) {
+	case "major_brand":
+		m.MajorBrand = v
+	case "minor_version":
+		m.MinorVersion = v
+	case "compatible_brands":
+		m.CompatibleBrands = v
+	case "title":
+		m.Title = v
+	case "artist":
+		m.Artist = v
+	case "date":
+		m.Date = v
+	case "encoder":
+		m.Encoder = v
+	default:
+		if m.Other == nil {
+			m.Other = make(map[string]string)
+		}
+		m.Other[k] = v
 	}
-	if m.Date != "" {
-		fmt.Fprintf(b, "date=%s\n", m.Date)
+}
+
+// tagStream gets the index of the stream that has the file's tags, or -1 if
+// the tags are stored globally.
+//
+// Ogg (Vorbis, Opus, etc.) stores all tags as Vorbis comments in the first audio
+// stream, rather than the container.
+func tagStream(info ProbeFile) int {
+	if info.Format.FormatName != "ogg" {
+		return -1
 	}
-	for _, k := range zmap.KeysOrdered(m.Other) {
-		fmt.Fprintf(b, "%s=%s\n", k, strings.ReplaceAll(m.Other[k], "\n", "\\\n"))
+	for _, s := range info.Streams {
+		if s.Audio() {
+			return s.Index
+		}
 	}
+	return -1
+}
 
-	for _, c := range m.Chapters {
-		b.WriteString("[CHAPTER]\n")
-		fmt.Fprintf(b, "TIMEBASE=%d/%d\n", c.Timebase[0], c.Timebase[1])
-		fmt.Fprintf(b, "START=%d\n", c.Start)
-		fmt.Fprintf(b, "END=%d\n", c.End)
-		fmt.Fprintf(b, "title=%s\n", c.Title)
-	}
-	return b.String()
+// WriteMeta writes the file with the metadata set to m. The input file is
+// replaced if output is the same as input.
+//
+// The metadata of the streams in m.Streams is replaced; streams not in the
+// list are left alone. For Ogg files the tags are written to the first audio
+// stream. Data streams are not copied, as with Editor.Write.
+func WriteMeta(ctx context.Context, m Meta, input, output string) error {
+	e := Edit(input).SetMeta(m)
+	e.op = "wtff.WriteMeta"
+	return e.Write(ctx, output)
 }
 
 func tomlStr(w io.Writer, width int, key string, val string) {
@@ -350,19 +347,29 @@ func (m Meta) TOML() string {
 	enc := toml.NewEncoder(b)
 	pad := ""
 	for _, c := range m.Chapters {
-		if c.StartSecs() > 3600 {
+		if c.StartTime() >= time.Hour {
 			pad = "   "
 			break
 		}
 	}
 
-	for _, c := range m.Chapters {
-		s := c.StartSecs()
-		t := fmt.Sprintf(`%s"%02d:%02d"`, pad, s/60, s%60)
-		if s > 3600 {
-			t = fmt.Sprintf(`"%02d:%02d:%02d"`, s/3600, s/60%60, s%60)
+	for i, c := range m.Chapters {
+		st := c.StartTime()
+		t := `"` + fmtChapterTime(st, st >= time.Hour) + `"`
+		if st < time.Hour {
+			t = pad + t
+		}
+		fmt.Fprintf(b, `    {start = %s, `, t)
+
+		// Only write the end if it's not the default.
+		end, next := c.EndTime(), m.Duration
+		if i < len(m.Chapters)-1 {
+			next = m.Chapters[i+1].StartTime()
+		}
+		if end > 0 && end != next && (next > 0 || i < len(m.Chapters)-1) {
+			fmt.Fprintf(b, `end = "%s", `, fmtChapterTime(end, end >= time.Hour))
 		}
-		fmt.Fprintf(b, `    {start = %s, title = `, t)
+		b.WriteString(`title = `)
 		enc.Encode(c.Title)
 		b.WriteString("},\n")
 	}
@@ -381,5 +388,26 @@ func (m Meta) TOML() string {
 		b.WriteString("\n")
 	}
 
+	for _, s := range m.Streams {
+		b.WriteString("[[streams]]\n")
+		if s.Codec != "" {
+			fmt.Fprintf(b, "    # %s\n", s.Codec)
+		}
+		fmt.Fprintf(b, "    %-8s = %d\n", "index", s.Index)
+		b.WriteString("    ")
+		tomlStr(b, 8, "language", s.Language)
+		b.WriteString("    ")
+		tomlStr(b, 8, "title", s.Title)
+		if len(s.Tags) > 0 {
+			b.WriteString("    [streams.tags]\n")
+			l := zmap.LongestKey(s.Tags) + 2
+			for _, k := range zmap.KeysOrdered(s.Tags) {
+				b.WriteString("        ")
+				tomlStr(b, l, k, s.Tags[k])
+			}
+		}
+		b.WriteString("\n")
+	}
+
 	return b.String()
 }
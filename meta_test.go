@@ -0,0 +1,49 @@
+package wtff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMetaFromTOML(t *testing.T) {
+	in := "[[chapters]]\nstart = \"0:00\"\ntitle = \"One\"\n\n[[chapters]]\nstart = \"1:00\"\ntitle = \"Two\"\n"
+
+	m, err := ParseMetaFromTOML(in, 90*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := m.Chapters[1].EndTime(); have != 90*time.Second {
+		t.Errorf("wrong end: %s", have)
+	}
+
+	m, err = ParseMetaFromTOML(in, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := m.Chapters[1].End; have != 0 {
+		t.Errorf("wrong end: %d", have)
+	}
+}
+
+func TestParseMetaFromTOMLError(t *testing.T) {
+	tests := []struct {
+		in       string
+		duration time.Duration
+		wantErr  string
+	}{
+		{"[[chapters]]\nstart = \"x\"\n", 0, `chapter 1 (""): invalid start`},
+		{"[[chapters]]\nstart = \"1:00\"\n[[chapters]]\nstart = \"0:30\"\n", 0, "chapter 2 (\"\") starts after the preceding"},
+		{"[[chapters]]\nstart = \"0:00\"\n[[chapters]]\nstart = \"2:00\"\ntitle = \"Two\"\n", 90 * time.Second, `chapter 2 ("Two") starts after the end of the file`},
+		{"[[chapters]]\nstart = \"0:10\"\nend = \"0:05\"\n", 0, "ends before it starts"},
+		{"[[streams]]\nindex = 1\n[[streams]]\nindex = 1\n", 0, "stream 1 appears more than once"},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseMetaFromTOML(tt.in, tt.duration)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package wtff
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type (
+	// Packet is a single packet of a stream.
+	Packet struct {
+		PTS      time.Duration // Presentation time, relative to the start of the file.
+		DTS      time.Duration // Decoding time, relative to the start of the file.
+		Duration time.Duration // May be 0 if unknown.
+		Size     Byte
+		Keyframe bool
+	}
+
+	// GOP is a "group of pictures": a keyframe and all packets up to the next
+	// keyframe.
+	GOP struct {
+		Start    time.Duration // Time of the keyframe.
+		Duration time.Duration // Time until the next keyframe.
+		Frames   int           // Number of packets.
+		Size     Byte          // Total size of all packets.
+	}
+	GOPs []GOP
+)
+
+// ProbePackets gets all packets of the stream with the given index, in decoding
+// order. The first video stream is used if stream is -1.
+//
+// This reads the entire file, which may take a while for large files.
+func ProbePackets(ctx context.Context, file string, stream int) ([]Packet, error) {
+	info, err := Probe(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("wtff.ProbePackets: %w", err)
+	}
+	stream, err = packetStream(info, stream)
+	if err != nil {
+		return nil, fmt.Errorf("wtff.ProbePackets: %w", err)
+	}
+	return probePackets(ctx, "wtff.ProbePackets", file, info, stream, "")
+}
+
+// Keyframes gets the keyframes of the stream with the given index, as a list of
+// GOPs. The first video stream is used if stream is -1.
+//
+// This reads the entire file, which may take a while for large files.
+func Keyframes(ctx context.Context, file string, stream int) (GOPs, error) {
+	info, err := Probe(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("wtff.Keyframes: %w", err)
+	}
+	stream, err = packetStream(info, stream)
+	if err != nil {
+		return nil, fmt.Errorf("wtff.Keyframes: %w", err)
+	}
+	packets, err := probePackets(ctx, "wtff.Keyframes", file, info, stream, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Packets before the first keyframe can't be decoded, so they're not part
+	// of any GOP.
+	var (
+		gops GOPs
+		end  time.Duration
+	)
+	for _, p := range packets {
+		if p.Keyframe {
+			gops = append(gops, GOP{Start: p.PTS})
+		}
+		if len(gops) == 0 {
+			continue
+		}
+		gops[len(gops)-1].Frames++
+		gops[len(gops)-1].Size += p.Size
+		end = max(end, p.PTS+p.Duration)
+	}
+	for i := range gops {
+		if i < len(gops)-1 {
+			gops[i].Duration = gops[i+1].Start - gops[i].Start
+		} else {
+			gops[i].Duration = end - gops[i].Start
+		}
+	}
+	return gops, nil
+}
+
+// Nearest gets the time of the last keyframe at or before t, and the first
+// keyframe at or after t. Either is -1 if there is no such keyframe.
+func (g GOPs) Nearest(t time.Duration) (before, after time.Duration) {
+	i, found := slices.BinarySearchFunc(g, t, func(gop GOP, t time.Duration) int { return cmp.Compare(gop.Start, t) })
+	if found {
+		return t, t
+	}
+	before, after = -1, -1
+	if i > 0 {
+		before = g[i-1].Start
+	}
+	if i < len(g) {
+		after = g[i].Start
+	}
+	return before, after
+}
+
+// Times gets the times of all keyframes.
+func (g GOPs) Times() []time.Duration {
+	t := make([]time.Duration, 0, len(g))
+	for _, gop := range g {
+		t = append(t, gop.Start)
+	}
+	return t
+}
+
+func packetStream(info ProbeFile, stream int) (int, error) {
+	if stream == -1 {
+		stream = info.Streams.videoStream()
+		if stream == -1 {
+			return -1, fmt.Errorf("no video stream")
+		}
+		return stream, nil
+	}
+	if !info.Streams.Contains(stream) {
+		return -1, fmt.Errorf("no stream with index %d", stream)
+	}
+	return stream, nil
+}
+
+// probePackets gets the packets of stream, optionally limited to the ffprobe
+// -read_intervals in interval.
+func probePackets(ctx context.Context, op, file string, info ProbeFile, stream int, interval string) ([]Packet, error) {
+	args := []string{"-v", "error",
+		"-select_streams", strconv.Itoa(stream),
+		"-show_entries", "packet=pts_time,dts_time,duration_time,size,flags",
+		"-of", "json=compact=1"}
+	if interval != "" {
+		args = append(args, "-read_intervals", interval)
+	}
+	out, err := ffprobe(ctx, op, append(args, file)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var outj struct {
+		Packets []struct {
+			PTS      string `json:"pts_time"`
+			DTS      string `json:"dts_time"`
+			Duration string `json:"duration_time"`
+			Size     Byte   `json:"size"`
+			Flags    string `json:"flags"`
+		} `json:"packets"`
+	}
+	err = json.Unmarshal(out, &outj)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		off     = info.Format.StartTime.Duration
+		packets = make([]Packet, 0, len(outj.Packets))
+		secs    = func(s string) (time.Duration, bool) {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil { // "N/A"
+				return 0, false
+			}
+			return time.Duration(f * float64(time.Second)).Round(time.Microsecond), true
+		}
+	)
+	for _, p := range outj.Packets {
+		pts, okPTS := secs(p.PTS)
+		dts, okDTS := secs(p.DTS)
+		switch {
+		case !okPTS && !okDTS:
+			continue
+		case !okPTS:
+			pts = dts
+		case !okDTS:
+			dts = pts
+		}
+		dur, _ := secs(p.Duration)
+		packets = append(packets, Packet{
+			PTS:      pts - off,
+			DTS:      dts - off,
+			Duration: dur,
+			Size:     p.Size,
+			Keyframe: len(p.Flags) > 0 && p.Flags[0] == 'K',
+		})
+	}
+	return packets, nil
+}
+
+// keyframes gets the times of the keyframes in the stream between from and to,
+// starting with the last keyframe before from.
+func keyframes(ctx context.Context, op, input string, info ProbeFile, stream int, from, to time.Duration) ([]time.Duration, error) {
+	off := info.Format.StartTime.Duration
+	packets, err := probePackets(ctx, op, input, info, stream, fmtSeconds(off+from)+"%"+fmtSeconds(off+to))
+	if err != nil {
+		return nil, err
+	}
+	var kf []time.Duration
+	for _, p := range packets {
+		if p.Keyframe {
+			kf = append(kf, p.PTS)
+		}
+	}
+	slices.Sort(kf)
+	return kf, nil
+}
@@ -0,0 +1,197 @@
+package wtff
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"zgo.at/zstd/zfilepath"
+)
+
+// Plan records the commands an operation would run in dry-run mode.
+type Plan struct {
+	mu   sync.Mutex
+	cmds []string
+	tmp  []string
+}
+
+type planKey struct{}
+
+// DryRun returns a context which makes all operations record the commands
+// they would run in the returned Plan, instead of running them.
+//
+// Nothing is written to the filesystem in dry-run mode, but files are still
+// read to get the information needed to construct the commands.
+func DryRun(ctx context.Context) (context.Context, *Plan) {
+	p := new(Plan)
+	return context.WithValue(ctx, planKey{}, p), p
+}
+
+func getPlan(ctx context.Context) *Plan {
+	p, _ := ctx.Value(planKey{}).(*Plan)
+	return p
+}
+
+// Commands gets all recorded commands as shell-quoted strings.
+func (p *Plan) Commands() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.cmds)
+}
+
+// String gets all recorded commands as a shell script.
+func (p *Plan) String() string {
+	return strings.Join(p.Commands(), "\n")
+}
+
+func (p *Plan) add(prog string, args ...string) {
+	p.addRaw(shellJoin(prog, args...))
+}
+
+func (p *Plan) addRaw(cmd string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cmds = append(p.cmds, cmd)
+}
+
+// WriteFile writes data to path, or records it as a "cat" command in dry-run
+// mode.
+func WriteFile(ctx context.Context, path, data string) error {
+	if p := getPlan(ctx); p != nil {
+		p.addRaw(heredoc(path, data))
+		return nil
+	}
+	return os.WriteFile(path, []byte(data), 0o666)
+}
+
+func heredoc(path, data string) string {
+	return "cat >" + shellQuote(path) + " <<'WTFF_EOF'\n" + strings.TrimSuffix(data, "\n") + "\nWTFF_EOF"
+}
+
+// writeTemp writes data to a new temporary file in dir and returns the path.
+// It uses the system's temporary directory if dir is "".
+func writeTemp(ctx context.Context, dir, pattern, data string) (string, error) {
+	if p := getPlan(ctx); p != nil {
+		name := p.tmpName(dir, pattern)
+		p.addRaw(heredoc(name, data))
+		return name, nil
+	}
+
+	fp, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = fp.WriteString(data)
+	if err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return "", err
+	}
+	err = fp.Close()
+	if err != nil {
+		os.Remove(fp.Name())
+		return "", err
+	}
+	return fp.Name(), nil
+}
+
+// tmpPart creates an empty temporary file in dir with the extension ext, for
+// writing a part of the output to; it should be removed with remove().
+func tmpPart(ctx context.Context, dir, ext string) (string, error) {
+	pattern := "wtff-part-*." + ext
+	if p := getPlan(ctx); p != nil {
+		return p.tmpName(dir, pattern), nil
+	}
+
+	fp, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	return fp.Name(), fp.Close()
+}
+
+// tmpName gets a temporary filename in dry-run mode, which is recorded so that
+// remove() will print the "rm" command for it.
+func (p *Plan) tmpName(dir, pattern string) string {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := filepath.Join(dir, strings.Replace(pattern, "*", "dryrun"+strconv.Itoa(len(p.tmp)+1), 1))
+	p.tmp = append(p.tmp, name)
+	return name
+}
+
+// tmpFile creates an empty temporary file next to path, for writing the output
+// to before renaming it to path.
+func tmpFile(ctx context.Context, path string) (string, error) {
+	base, ext := zfilepath.SplitExt(path)
+	pattern := filepath.Base(base) + "-wtff-meta-*." + ext
+	if getPlan(ctx) != nil {
+		return filepath.Join(filepath.Dir(path), strings.Replace(pattern, "*", "dryrun", 1)), nil
+	}
+
+	fp, err := os.CreateTemp(filepath.Dir(path), pattern)
+	if err != nil {
+		return "", err
+	}
+	return fp.Name(), fp.Close()
+}
+
+// rename a file, or record a "mv" command in dry-run mode.
+func rename(ctx context.Context, from, to string) error {
+	if p := getPlan(ctx); p != nil {
+		p.add("mv", "-f", from, to)
+		return nil
+	}
+	return os.Rename(from, to)
+}
+
+// remove temporary files, ignoring errors. In dry-run mode only files created
+// with writeTemp() are recorded as a "rm" command.
+func remove(ctx context.Context, paths ...string) {
+	if p := getPlan(ctx); p != nil {
+		var rm []string
+		p.mu.Lock()
+		for _, path := range paths {
+			if slices.Contains(p.tmp, path) {
+				rm = append(rm, path)
+			}
+		}
+		p.mu.Unlock()
+		if len(rm) > 0 {
+			p.add("rm", append([]string{"-f"}, rm...)...)
+		}
+		return
+	}
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+func shellJoin(prog string, args ...string) string {
+	qa := make([]string, 0, len(args)+1)
+	qa = append(qa, shellQuote(prog))
+	for _, a := range args {
+		qa = append(qa, shellQuote(a))
+	}
+	return strings.Join(qa, " ")
+}
+
+func shellQuote(s string) string {
+	if len(s) == 0 {
+		return "''"
+	}
+	if !strings.ContainsAny(s, "\\'\"`${[|&;<>()*?! \t\n") && s[0] != '~' {
+		return s
+	}
+	if strings.Contains(s, "'") && !strings.ContainsAny(s, "\\\"$`!") {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,157 @@
+package wtff
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadPlaylist reads the list of files from an M3U or M3U8 playlist, or a plain
+// list file with one file per line.
+//
+// Empty lines and lines starting with "#" (such as "#EXTINF") are skipped.
+// Relative paths are resolved against the directory of the playlist, and
+// file:// URLs are converted to paths. Other URLs are returned as-is.
+func ReadPlaylist(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("wtff.ReadPlaylist: %w", err)
+	}
+
+	var (
+		dir   = filepath.Dir(file)
+		files []string
+	)
+	for i, line := range strings.Split(strings.TrimPrefix(string(data), "\ufeff"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "file://") {
+			u, err := url.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("wtff.ReadPlaylist: line %d: %w", i+1, err)
+			}
+			line = filepath.FromSlash(u.Path)
+		} else if strings.Contains(line, "://") {
+			files = append(files, line)
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		files = append(files, line)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("wtff.ReadPlaylist: %q has no files", file)
+	}
+	return files, nil
+}
+
+// SortInputs sorts the files in-place. The order is one of:
+//
+//	natural      By filename, with numbers compared by their value, so that
+//	             "part2" sorts before "part10".
+//	mtime        By modification time, oldest first.
+//	meta-track   By the disc and track number tags; files without a disc
+//	             number are on disc 1, and files without a track number sort
+//	             after files with one.
+//
+// Files that are equal are sorted by their natural order.
+func SortInputs(ctx context.Context, order string, files []string) error {
+	switch order {
+	case "natural":
+		slices.SortStableFunc(files, compareNatural)
+	case "mtime":
+		mtimes := make(map[string]time.Time, len(files))
+		for _, f := range files {
+			st, err := os.Stat(f)
+			if err != nil {
+				return fmt.Errorf("wtff.SortInputs: %w", err)
+			}
+			mtimes[f] = st.ModTime()
+		}
+		slices.SortStableFunc(files, func(a, b string) int {
+			return cmp.Or(mtimes[a].Compare(mtimes[b]), compareNatural(a, b))
+		})
+	case "meta-track":
+		type track struct{ disc, track int }
+		tracks := make(map[string]track, len(files))
+		for _, f := range files {
+			m, err := ReadMeta(ctx, f)
+			if err != nil {
+				return fmt.Errorf("wtff.SortInputs: %w", err)
+			}
+			t := track{disc: tagNumber(m, "disc"), track: tagNumber(m, "track")}
+			if t.disc == math.MaxInt {
+				t.disc = 1
+			}
+			tracks[f] = t
+		}
+		slices.SortStableFunc(files, func(a, b string) int {
+			ta, tb := tracks[a], tracks[b]
+			return cmp.Or(cmp.Compare(ta.disc, tb.disc), cmp.Compare(ta.track, tb.track), compareNatural(a, b))
+		})
+	default:
+		return fmt.Errorf("wtff.SortInputs: unknown order: %q", order)
+	}
+	return nil
+}
+
+// tagNumber gets the number from a "track" or "disc" tag, which may be as "3"
+// or "3/12". It returns math.MaxInt if the tag isn't set or isn't a number.
+func tagNumber(m Meta, tag string) int {
+	for k, v := range m.Other {
+		if !strings.EqualFold(k, tag) {
+			continue
+		}
+		v, _, _ = strings.Cut(strings.TrimSpace(v), "/")
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			break
+		}
+		return n
+	}
+	return math.MaxInt
+}
+
+// compareNatural compares a and b with runs of digits compared by their
+// numeric value, and everything else compared case-insensitively.
+func compareNatural(a, b string) int {
+	x, y := strings.ToLower(a), strings.ToLower(b)
+	for x != "" && y != "" {
+		dx, dy := digits(x), digits(y)
+		if dx == 0 || dy == 0 {
+			if x[0] != y[0] {
+				return cmp.Compare(x[0], y[0])
+			}
+			x, y = x[1:], y[1:]
+			continue
+		}
+
+		nx, ny := strings.TrimLeft(x[:dx], "0"), strings.TrimLeft(y[:dy], "0")
+		if c := cmp.Or(cmp.Compare(len(nx), len(ny)), strings.Compare(nx, ny)); c != 0 {
+			return c
+		}
+		x, y = x[dx:], y[dy:]
+	}
+	return cmp.Or(cmp.Compare(len(x), len(y)), strings.Compare(a, b))
+}
+
+// digits gets the number of leading ASCII digits in s.
+func digits(s string) int {
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -3,13 +3,12 @@ package wtff
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"math"
 	"strconv"
 	"strings"
 	"time"
-
-	"zgo.at/zstd/zbyte"
 )
 
 func (t Time) MarshalText() ([]byte, error) {
@@ -21,10 +20,156 @@ func (t *Time) UnmarshalText(b []byte) error {
 	if err != nil {
 		return err
 	}
-	t.Duration = time.Duration(f) * time.Second
+	t.Duration = time.Duration(math.Round(f * float64(time.Second)))
 	return nil
 }
 
+// ParseTime parses a time, which can be in any of these formats:
+//
+//	[HH:]MM:SS[.frac]   01:33, 01:01:33.5
+//	duration            1h2m3s, 1m30.5s, 500ms
+//	seconds             93, 93.5
+//	-time               relative to the end: -00:30 is the last 30 seconds
+//	fN                  frame number: f1234
+//	HH:MM:SS:FF         SMPTE timecode
+//	HH:MM:SS;FF         SMPTE drop-frame timecode; also with ";" or "." for
+//	                    all separators
+//
+// The duration of info is used for times relative to the end, and the frame
+// rate of the first video stream for frame numbers and timecodes; info can be
+// empty if that's not needed.
+func ParseTime(s string, info ProbeFile) (Time, error) {
+	s = strings.TrimSpace(s)
+	if rel, ok := strings.CutPrefix(s, "-"); ok {
+		if strings.HasPrefix(rel, "-") {
+			return Time{}, fmt.Errorf("invalid time %q", s)
+		}
+		t, err := ParseTime(rel, info)
+		if err != nil {
+			return t, err
+		}
+		total := info.Format.Duration.Duration
+		if total == 0 {
+			return Time{}, fmt.Errorf("invalid time %q: duration of the file is unknown", s)
+		}
+		if t.Duration > total {
+			return Time{}, fmt.Errorf("invalid time %q: longer than the file (%s)", s, info.Format.Duration)
+		}
+		return Time{Duration: total - t.Duration}, nil
+	}
+
+	fps := func() (float64, error) {
+		if v := info.Streams.videoStream(); v > -1 {
+			if r := info.Streams[v].FrameRate(); r > 0 {
+				return r, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid time %q: frame rate is unknown", s)
+	}
+
+	switch {
+	case s == "":
+		return Time{}, errors.New("invalid time: empty string")
+	case s[0] == 'f':
+		n, err := strconv.ParseUint(s[1:], 10, 64)
+		if err != nil {
+			return Time{}, fmt.Errorf("invalid frame number %q", s)
+		}
+		r, err := fps()
+		if err != nil {
+			return Time{}, err
+		}
+		return Time{Duration: time.Duration(math.Round(float64(n) / r * float64(time.Second)))}, nil
+	case strings.Count(s, ":") == 3 || strings.ContainsRune(s, ';') || strings.Count(s, ".") == 3:
+		r, err := fps()
+		if err != nil {
+			return Time{}, err
+		}
+		return parseTimecode(s, r)
+	case strings.Contains(s, ":"):
+		return parseClock(s)
+	case strings.ContainsAny(s, "hms"):
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return Time{}, fmt.Errorf("invalid time %q", s)
+		}
+		return Time{Duration: d}, nil
+	default:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return Time{}, fmt.Errorf("invalid time %q", s)
+		}
+		return Time{Duration: time.Duration(math.Round(f * float64(time.Second)))}, nil
+	}
+}
+
+// parseClock parses "[HH:]MM:SS[.frac]".
+func parseClock(s string) (Time, error) {
+	sp := strings.Split(s, ":")
+	if len(sp) > 3 {
+		return Time{}, fmt.Errorf("invalid time %q: too many :", s)
+	}
+	var sub time.Duration
+	if sec, frac, ok := strings.Cut(sp[len(sp)-1], "."); ok {
+		var err error
+		sub, err = time.ParseDuration("0." + frac + "s")
+		if err != nil || strings.ContainsAny(frac, "+-") {
+			return Time{}, fmt.Errorf("invalid time %q", s)
+		}
+		sp[len(sp)-1] = sec
+	}
+
+	var d time.Duration
+	for _, n := range sp {
+		i, err := strconv.ParseUint(n, 10, 32)
+		if err != nil {
+			return Time{}, fmt.Errorf("invalid time %q", s)
+		}
+		d = d*60 + time.Duration(i)*time.Second
+	}
+	return Time{Duration: d + sub}, nil
+}
+
+// parseTimecode parses a SMPTE timecode "HH:MM:SS:FF", or a drop-frame timecode
+// if ";" or "." is used as a separator.
+func parseTimecode(s string, fps float64) (Time, error) {
+	drop := strings.ContainsAny(s, ";.")
+	sp := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == ';' || r == '.' })
+	if len(sp) != 4 {
+		return Time{}, fmt.Errorf("invalid timecode %q", s)
+	}
+	var n [4]int64
+	for i := range sp {
+		var err error
+		n[i], err = strconv.ParseInt(sp[i], 10, 64)
+		if err != nil || n[i] < 0 {
+			return Time{}, fmt.Errorf("invalid timecode %q", s)
+		}
+	}
+
+	nominal := int64(math.Round(fps))
+	if n[1] > 59 || n[2] > 59 || n[3] >= nominal {
+		return Time{}, fmt.Errorf("invalid timecode %q for %.3f fps", s, fps)
+	}
+	frame := ((n[0]*60+n[1])*60+n[2])*nominal + n[3]
+	if drop {
+		// Drop-frame timecode skips the first frame numbers of every minute,
+		// except every tenth minute: 2 for 29.97 fps and 4 for 59.94 fps.
+		if nominal != 30 && nominal != 60 || math.Abs(fps-float64(nominal)*1000/1001) > 0.01 {
+			return Time{}, fmt.Errorf("invalid timecode %q: drop-frame timecode needs 29.97 or 59.94 fps, not %.3f", s, fps)
+		}
+		var (
+			dropped = nominal / 15
+			minutes = n[0]*60 + n[1]
+		)
+		if n[2] == 0 && n[3] < dropped && minutes%10 != 0 {
+			return Time{}, fmt.Errorf("invalid timecode %q: frame %d is dropped", s, n[3])
+		}
+		frame -= dropped * (minutes - minutes/10)
+	}
+	return Time{Duration: time.Duration(math.Round(float64(frame) / fps * float64(time.Second)))}, nil
+}
+
 func (t Time) String() string {
 	h, m, s := t.Truncate(time.Hour).Hours(), t.Truncate(time.Minute).Minutes(), t.Truncate(time.Second).Seconds()
 	f := fmt.Sprintf("%02d:%02d", int(m)%60, int(s)%60)
@@ -33,7 +178,7 @@ func (t Time) String() string {
 	}
 	r := t.Duration % time.Second
 	if r > 0 {
-		f = strings.TrimRight(fmt.Sprintf("%s.%d", f, r), "0")
+		f = strings.TrimRight(fmt.Sprintf("%s.%09d", f, r), "0")
 	}
 	return f
 }
@@ -97,21 +242,22 @@ type (
 		DurationTs uint   `json:"duration_ts,omitempty"` // 1746601
 		Duration   Time   `json:"duration,omitempty"`    // "1746.601000"
 
-		Disposition struct {
-			Default         uint `json:"default,omitempty"`
-			Dub             uint `json:"dub,omitempty"`
-			Original        uint `json:"original,omitempty"`
-			Comment         uint `json:"comment,omitempty"`
-			Lyrics          uint `json:"lyrics,omitempty"`
-			Karaoke         uint `json:"karaoke,omitempty"`
-			Forced          uint `json:"forced,omitempty"`
-			HearingImpaired uint `json:"hearing_impaired,omitempty"`
-			VisualImpaired  uint `json:"visual_impaired,omitempty"`
-			CleanEffects    uint `json:"clean_effects,omitempty"`
-			AttachedPic     uint `json:"attached_pic,omitempty"`
-			TimedThumbnails uint `json:"timed_thumbnails,omitempty"`
-		} `json:"disposition,omitempty"`
-		Tags map[string]any `json:"tags,omitempty"`
+		Disposition Disposition    `json:"disposition,omitempty"`
+		Tags        map[string]any `json:"tags,omitempty"`
+	}
+	Disposition struct {
+		Default         uint `json:"default,omitempty"`
+		Dub             uint `json:"dub,omitempty"`
+		Original        uint `json:"original,omitempty"`
+		Comment         uint `json:"comment,omitempty"`
+		Lyrics          uint `json:"lyrics,omitempty"`
+		Karaoke         uint `json:"karaoke,omitempty"`
+		Forced          uint `json:"forced,omitempty"`
+		HearingImpaired uint `json:"hearing_impaired,omitempty"`
+		VisualImpaired  uint `json:"visual_impaired,omitempty"`
+		CleanEffects    uint `json:"clean_effects,omitempty"`
+		AttachedPic     uint `json:"attached_pic,omitempty"`
+		TimedThumbnails uint `json:"timed_thumbnails,omitempty"`
 	}
 	Chapter struct {
 		Id        int            `json:"id,omitempty"`         // -1349333221,
@@ -173,10 +319,10 @@ func (p ProbeFile) String() string {
 
 // Probe gets an overview of streams for this file.
 func Probe(ctx context.Context, file string) (ProbeFile, error) {
-	out, err := exec.CommandContext(ctx, "ffprobe", "-hide_banner", "-v", "quiet",
+	out, err := ffprobe(ctx, "wtff.Probe", "-v", "quiet",
 		"-of", "json=compact=1",
 		"-show_error", "-show_format", "-show_streams", "-show_chapters",
-		file).CombinedOutput()
+		file)
 	if err != nil {
 		var outj struct {
 			Error struct {
@@ -184,11 +330,12 @@ func Probe(ctx context.Context, file string) (ProbeFile, error) {
 				String string `json:"string"`
 			} `json:"error"`
 		}
-		errj := json.Unmarshal(out, &outj)
-		if errj == nil {
-			return ProbeFile{}, fmt.Errorf("wtff.Probe: %w: %s (code %d)", err, outj.Error.String, outj.Error.Code)
+		var ffErr *FFError
+		if errors.As(err, &ffErr) && json.Unmarshal(out, &outj) == nil && outj.Error.Code != 0 {
+			ffErr.Code = outj.Error.Code
+			ffErr.Errors = append(ffErr.Errors, fmt.Sprintf("%s (code %d)", outj.Error.String, outj.Error.Code))
 		}
-		return ProbeFile{}, fmt.Errorf("wtff.Probe: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return ProbeFile{}, err
 	}
 
 	var p ProbeFile
@@ -203,6 +350,54 @@ func (s Stream) Subtitle() bool { return s.CodecType == "subtitle" }
 func (s Stream) Video() bool    { return s.CodecType == "video" }
 func (s Stream) Audio() bool    { return s.CodecType == "audio" }
 
+// FrameRate gets the average frame rate, or 0 if it's not known.
+func (s Stream) FrameRate() float64 {
+	for _, r := range []string{s.AvgFrameRate, s.RFrameRate} {
+		num, den, ok := strings.Cut(r, "/")
+		if !ok {
+			continue
+		}
+		n, err1 := strconv.ParseFloat(num, 64)
+		d, err2 := strconv.ParseFloat(den, 64)
+		if err1 == nil && err2 == nil && n > 0 && d > 0 {
+			return n / d
+		}
+	}
+	return 0
+}
+
+// Lang gets the stream language, or "und" if it's not set.
+func (s Stream) Lang() string {
+	if l, ok := s.Tags["language"].(string); ok && l != "" {
+		return l
+	}
+	return "und"
+}
+
+// List gets the names of all dispositions that are set, as used by ffmpeg.
+func (d Disposition) List() []string {
+	var l []string
+	for _, dd := range []struct {
+		name string
+		v    uint
+	}{
+		{"default", d.Default}, {"dub", d.Dub}, {"original", d.Original},
+		{"comment", d.Comment}, {"lyrics", d.Lyrics}, {"karaoke", d.Karaoke},
+		{"forced", d.Forced}, {"hearing_impaired", d.HearingImpaired},
+		{"visual_impaired", d.VisualImpaired}, {"clean_effects", d.CleanEffects},
+		{"attached_pic", d.AttachedPic}, {"timed_thumbnails", d.TimedThumbnails},
+	} {
+		if dd.v > 0 {
+			l = append(l, dd.name)
+		}
+	}
+	return l
+}
+
+// Find the stream index of the first stream of kind by stream index or
+// language, or -1 if nothing is found.
+//
+// Deprecated: use SelectKind, which can match many more things.
 func (s Streams) Find(kind, langOrNum string) int {
 	streamN, err := strconv.Atoi(langOrNum)
 	if err != nil {
@@ -224,3 +419,30 @@ func (s Streams) Find(kind, langOrNum string) int {
 	}
 	return -1
 }
+
+// videoStream gets the index of the first video stream that's not a cover
+// image, or -1 if there is none.
+func (s Streams) videoStream() int {
+	for _, ss := range s {
+		if ss.Video() && ss.Disposition.AttachedPic == 0 {
+			return ss.Index
+		}
+	}
+	return -1
+}
+
+// audioStream gets the index of the first audio stream, or -1 if there is
+// none.
+func (s Streams) audioStream() int {
+	for _, ss := range s {
+		if ss.Audio() {
+			return ss.Index
+		}
+	}
+	return -1
+}
+
+// fmtSeconds formats d as seconds, as accepted by ffmpeg.
+func fmtSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
@@ -0,0 +1,152 @@
+package wtff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	var (
+		none  ProbeFile
+		pal   = probeFile(100*time.Second, "25/1")
+		ntsc  = probeFile(100*time.Second, "30000/1001")
+		ntsc2 = probeFile(100*time.Second, "60000/1001")
+	)
+	tests := []struct {
+		in   string
+		info ProbeFile
+		want time.Duration
+	}{
+		// Clock.
+		{"01:33", none, 93 * time.Second},
+		{"1:33", none, 93 * time.Second},
+		{"01:01:33", none, time.Hour + 93*time.Second},
+		{"01:01:33.5", none, time.Hour + 93500*time.Millisecond},
+		{"00:00:00.123456789", none, 123456789},
+		{"00:01:00.02", ntsc, 60020 * time.Millisecond}, // Not a timecode.
+		{"  01:33  ", none, 93 * time.Second},
+
+		// Duration.
+		{"1h2m3s", none, time.Hour + 2*time.Minute + 3*time.Second},
+		{"1m30.5s", none, 90500 * time.Millisecond},
+		{"500ms", none, 500 * time.Millisecond},
+
+		// Seconds.
+		{"93", none, 93 * time.Second},
+		{"93.5", none, 93500 * time.Millisecond},
+		{"0.1", none, 100 * time.Millisecond},
+		{"0", none, 0},
+
+		// Relative to the end.
+		{"-00:30", pal, 70 * time.Second},
+		{"-1m", pal, 40 * time.Second},
+		{"-2.5", pal, 97500 * time.Millisecond},
+		{"-100", pal, 0},
+		{"-f250", pal, 90 * time.Second},
+
+		// Frame numbers.
+		{"f0", pal, 0},
+		{"f1234", pal, 49360 * time.Millisecond},
+		{"f1234", ntsc, 41174466667},
+
+		// Timecodes.
+		{"00:01:00:12", pal, 60480 * time.Millisecond},
+		{"01:00:00:00", pal, time.Hour},
+		{"00:00:01:29", ntsc, 1_968_633_333},
+		{"00:01:00;02", ntsc, 60060 * time.Millisecond},
+		{"00.01.00.02", ntsc, 60060 * time.Millisecond},
+		{"00:10:00;00", ntsc, 599999400000},
+		{"00:01:00;04", ntsc2, 60060 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			have, err := ParseTime(tt.in, tt.info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have.Duration != tt.want {
+				t.Errorf("\nhave: %s\nwant: %s", have.Duration, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeError(t *testing.T) {
+	var (
+		none = ProbeFile{}
+		pal  = probeFile(100*time.Second, "25/1")
+		ntsc = probeFile(100*time.Second, "30000/1001")
+	)
+	tests := []struct {
+		in      string
+		info    ProbeFile
+		wantErr string
+	}{
+		{"", none, "empty string"},
+		{"   ", none, "empty string"},
+		{"abc", none, "invalid time"},
+		{"inf", none, "invalid time"},
+		{"NaN", none, "invalid time"},
+		{"1.5.3", none, "invalid time"},
+		{"1m-5s", none, "invalid time"},
+		{"1:xx", none, "invalid time"},
+		{"01:33.+5", none, "invalid time"},
+		{"1:2:3:4:5", pal, "too many :"},
+
+		{"-5", none, "duration of the file is unknown"},
+		{"-200", pal, "longer than the file"},
+		{"--5", pal, "invalid time"},
+
+		{"f", pal, "invalid frame number"},
+		{"f12x", pal, "invalid frame number"},
+		{"f-1", pal, "invalid frame number"},
+		{"f100", none, "frame rate is unknown"},
+
+		{"00:00:01:00", none, "frame rate is unknown"},
+		{"00:00:00:25", pal, "invalid timecode"},
+		{"00:60:00:00", pal, "invalid timecode"},
+		{"00:00:x:00", pal, "invalid timecode"},
+		{"00:01:00;00", ntsc, "frame 0 is dropped"},
+		{"00:01:00;01", ntsc, "frame 1 is dropped"},
+		{"00:00:01;00", pal, "drop-frame timecode needs 29.97 or 59.94 fps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := ParseTime(tt.in, tt.info)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeUnmarshalText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"0", 0},
+		{"0.1", 100 * time.Millisecond},
+		{"1.001", 1001 * time.Millisecond},
+		{"3600.000000", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var have Time
+			if err := have.UnmarshalText([]byte(tt.in)); err != nil {
+				t.Fatal(err)
+			}
+			if have.Duration != tt.want {
+				t.Errorf("\nhave: %s\nwant: %s", have.Duration, tt.want)
+			}
+		})
+	}
+}
+
+func probeFile(d time.Duration, fps string) ProbeFile {
+	var p ProbeFile
+	p.Format.Duration = Time{Duration: d}
+	p.Streams = Streams{{Index: 0, CodecType: "video", AvgFrameRate: fps}}
+	return p
+}
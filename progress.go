@@ -0,0 +1,98 @@
+package wtff
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is reported periodically by long-running ffmpeg operations.
+type Progress struct {
+	OutTime  time.Duration // Position in the output written so far.
+	Duration time.Duration // Expected total duration of the output; may be 0 if unknown.
+	Percent  float64       // Percentage of Duration; 0 if Duration is unknown.
+	Speed    float64       // Processing speed, as multiple of real time.
+	ETA      time.Duration // Estimated time remaining; 0 if unknown.
+	Done     bool          // Set on the final report.
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context which makes operations that support it call f
+// periodically with the progress.
+//
+// This is supported by Cut, CutAccurate, CutRanges, Cat, Split, SplitEvery,
+// SplitSize, SubAdd, SubRm, AudioAdd, AudioRm, WriteMeta, and Editor.Write.
+func WithProgress(ctx context.Context, f func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+func getProgress(ctx context.Context) func(Progress) {
+	f, _ := ctx.Value(progressKey{}).(func(Progress))
+	return f
+}
+
+// progressWriter parses the output of "ffmpeg -progress".
+type progressWriter struct {
+	f   func(Progress)
+	buf []byte
+	cur Progress
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.line(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+func (w *progressWriter) line(l string) {
+	k, v, ok := strings.Cut(strings.TrimSpace(l), "=")
+	if !ok {
+		return
+	}
+	switch k {
+	case "out_time_us":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err == nil && n > 0 {
+			w.cur.OutTime = time.Duration(n) * time.Microsecond
+		}
+	case "speed":
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "x"), 64)
+		if err == nil {
+			w.cur.Speed = n
+		}
+	case "progress":
+		w.cur.Done = v == "end"
+		if w.cur.Duration > 0 {
+			if w.cur.Done {
+				w.cur.OutTime = w.cur.Duration
+			}
+			w.cur.Percent = min(100, float64(w.cur.OutTime)/float64(w.cur.Duration)*100)
+			w.cur.ETA = 0
+			if w.cur.Speed > 0 && w.cur.OutTime < w.cur.Duration {
+				w.cur.ETA = time.Duration(float64(w.cur.Duration-w.cur.OutTime) / w.cur.Speed).Round(time.Second)
+			}
+		}
+		w.f(w.cur)
+	}
+}
+
+// ffmpegProgress runs ffmpeg, reporting the progress if the context has a
+// progress callback set. total is the expected duration of the output.
+func ffmpegProgress(ctx context.Context, op string, total time.Duration, args ...string) ([]byte, error) {
+	f := getProgress(ctx)
+	if f == nil || getPlan(ctx) != nil {
+		return ffmpeg(ctx, op, args...)
+	}
+	w := &progressWriter{f: f, cur: Progress{Duration: total}}
+	return run(ctx, op, w, "ffmpeg", append([]string{"-hide_banner", "-v", "level+warning", "-nostats", "-progress", "pipe:1"}, args...)...)
+}
@@ -0,0 +1,150 @@
+package wtff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// Runner runs ffmpeg and ffprobe.
+//
+// All operations in this package go through the Runner set with WithRunner, or
+// DefaultRunner if there is none. This can be used to run a specific ffmpeg
+// build, or to test code without actually running ffmpeg.
+type Runner interface {
+	// Run the program with the given arguments, writing the output to stdout
+	// and stderr. The program is either "ffmpeg" or "ffprobe".
+	Run(ctx context.Context, stdout, stderr io.Writer, prog string, args ...string) error
+}
+
+// DefaultRunner is the Runner used for all ffmpeg and ffprobe invocations if
+// the context has no Runner set with WithRunner.
+var DefaultRunner Runner = ExecRunner{}
+
+type runnerKey struct{}
+
+// WithRunner returns a context which makes all operations use r to run ffmpeg
+// and ffprobe, instead of DefaultRunner.
+func WithRunner(ctx context.Context, r Runner) context.Context {
+	return context.WithValue(ctx, runnerKey{}, r)
+}
+
+func getRunner(ctx context.Context) Runner {
+	if r, ok := ctx.Value(runnerKey{}).(Runner); ok && r != nil {
+		return r
+	}
+	return DefaultRunner
+}
+
+// ExecRunner runs ffmpeg and ffprobe as an external process.
+type ExecRunner struct {
+	FFmpeg  string // Path to ffmpeg; default is to look it up in $PATH.
+	FFprobe string // Path to ffprobe; default is to look it up in $PATH.
+}
+
+func (r ExecRunner) Run(ctx context.Context, stdout, stderr io.Writer, prog string, args ...string) error {
+	switch {
+	case prog == "ffmpeg" && r.FFmpeg != "":
+		prog = r.FFmpeg
+	case prog == "ffprobe" && r.FFprobe != "":
+		prog = r.FFprobe
+	}
+	cmd := exec.CommandContext(ctx, prog, args...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return cmd.Run()
+}
+
+// FakeRunner records all commands without running anything.
+type FakeRunner struct {
+	// Output is called for every command to get the output to return; may be
+	// nil, in which case every command succeeds with the output of an empty
+	// file: ffprobe returns no streams and no format information, and ffmpeg
+	// with "-f ffmetadata" returns an empty metadata file. Everything else
+	// returns no output.
+	Output func(prog string, args []string) (stdout, stderr string, err error)
+
+	mu   sync.Mutex
+	cmds [][]string
+}
+
+func (r *FakeRunner) Run(ctx context.Context, stdout, stderr io.Writer, prog string, args ...string) error {
+	r.mu.Lock()
+	r.cmds = append(r.cmds, append([]string{prog}, args...))
+	r.mu.Unlock()
+
+	if r.Output == nil {
+		io.WriteString(stdout, fakeOutput(prog, args))
+		return nil
+	}
+	o, e, err := r.Output(prog, args)
+	io.WriteString(stdout, o)
+	io.WriteString(stderr, e)
+	return err
+}
+
+func fakeOutput(prog string, args []string) string {
+	if prog == "ffprobe" {
+		return `{"streams":[],"format":{}}`
+	}
+	for i, a := range args {
+		if a == "-f" && i+1 < len(args) && args[i+1] == "ffmetadata" {
+			return ";FFMETADATA1\n"
+		}
+	}
+	return ""
+}
+
+// Commands gets all recorded commands; the first element of every command is
+// the program name.
+func (r *FakeRunner) Commands() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := make([][]string, len(r.cmds))
+	copy(c, r.cmds)
+	return c
+}
+
+// Reset clears the list of recorded commands.
+func (r *FakeRunner) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmds = nil
+}
+
+// run the program with the context's Runner and return stdout. If stdout is not nil
+// then stdout is written there, rather than being returned.
+//
+// The error will be an *FFError if the program fails; op is the operation
+// name for this error.
+func run(ctx context.Context, op string, stdout io.Writer, prog string, args ...string) ([]byte, error) {
+	if ShowFFCmd {
+		fmt.Fprintln(os.Stderr, shellJoin(prog, args...))
+	}
+	out, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if stdout == nil {
+		stdout = out
+	}
+	err := getRunner(ctx).Run(ctx, stdout, stderr, prog, args...)
+	if err != nil {
+		return out.Bytes(), newFFError(op, prog, args, stderr.String(), err)
+	}
+	return out.Bytes(), nil
+}
+
+// ffmpeg runs ffmpeg, or records the command in dry-run mode.
+func ffmpeg(ctx context.Context, op string, args ...string) ([]byte, error) {
+	args = append([]string{"-hide_banner", "-v", "level+warning"}, args...)
+	if p := getPlan(ctx); p != nil {
+		p.add("ffmpeg", args...)
+		return nil, nil
+	}
+	return run(ctx, op, nil, "ffmpeg", args...)
+}
+
+func ffprobe(ctx context.Context, op string, args ...string) ([]byte, error) {
+	return run(ctx, op, nil, "ffprobe", append([]string{"-hide_banner"}, args...)...)
+}
@@ -0,0 +1,91 @@
+package wtff
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestWithRunner(t *testing.T) {
+	for _, file := range []string{"a.mkv", "b.mkv"} {
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+			r := new(FakeRunner)
+			ctx := WithRunner(context.Background(), r)
+
+			_, err := Probe(ctx, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			have := r.Commands()
+			if len(have) != 1 || have[0][0] != "ffprobe" || have[0][len(have[0])-1] != file {
+				t.Errorf("wrong commands: %q", have)
+			}
+		})
+	}
+}
+
+func TestFakeRunner(t *testing.T) {
+	t.Run("zero", func(t *testing.T) {
+		r := new(FakeRunner)
+		ctx := WithRunner(context.Background(), r)
+
+		info, err := Probe(ctx, "file.mkv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(info.Streams) != 0 || info.Format.Duration.Duration != 0 {
+			t.Errorf("not empty: %#v", info)
+		}
+		m, err := ReadMeta(ctx, "file.mkv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.IsZero() {
+			t.Errorf("not empty: %#v", m)
+		}
+		if n := len(r.Commands()); n != 3 {
+			t.Errorf("%d commands", n)
+		}
+		r.Reset()
+		if n := len(r.Commands()); n != 0 {
+			t.Errorf("%d commands after Reset", n)
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		r := &FakeRunner{Output: func(prog string, args []string) (string, string, error) {
+			return "", "file.mkv: No such file or directory\n", errors.New("exit status 1")
+		}}
+		_, err := Probe(WithRunner(context.Background(), r), "file.mkv")
+
+		var ffErr *FFError
+		if !errors.As(err, &ffErr) {
+			t.Fatalf("not an FFError: %#v", err)
+		}
+		if ffErr.Op != "wtff.Probe" || ffErr.Prog != "ffprobe" {
+			t.Errorf("wrong op: %q %q", ffErr.Op, ffErr.Prog)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("not fs.ErrNotExist: %s", err)
+		}
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		r := new(FakeRunner)
+		ctx, plan := DryRun(WithRunner(context.Background(), r))
+		_, err := ffmpeg(ctx, "wtff.Test", "-i", "in.mkv", "out.mkv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(r.Commands()); n != 0 {
+			t.Errorf("ran %d commands in dry-run mode", n)
+		}
+		want := []string{"ffmpeg -hide_banner -v level+warning -i in.mkv out.mkv"}
+		if have := plan.Commands(); !reflect.DeepEqual(have, want) {
+			t.Errorf("\nhave: %q\nwant: %q", have, want)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package wtff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector selects streams.
+//
+// A selector is a comma-separated list of terms, and a stream is selected if it
+// matches any of the terms. A term is one or more conditions joined with "+",
+// all of which must match. A condition can be negated by prefixing it with "!".
+//
+// Conditions:
+//
+//	3                  Stream index 3, as reported by "wtff info".
+//	eng                Language; streams without a language are "und".
+//	ALL                All streams.
+//	a                  All streams of this type: v (video), a (audio),
+//	                   s (subtitle), d (data), or t (attachment).
+//	a:1                The second audio stream; counting starts at 0.
+//	a:eng              All English audio streams.
+//	a:!eng             All audio streams that are not English.
+//	codec=dts          Codec name.
+//	lang=eng           Language.
+//	title=Commentary   Title, matched exactly.
+//	title~commentary   Title contains the text, case-insensitive.
+//	disposition=forced Disposition is set.
+//	tag=value          Any other tag; also works with ~.
+//
+// For example:
+//
+//	a:!eng                All audio streams that are not English.
+//	s:eng+!disposition=forced,s:dut
+//	                      Non-forced English subtitles, and Dutch subtitles.
+//	!title~commentary     Everything except commentary tracks.
+type Selector struct {
+	terms [][]selCond
+}
+
+type selCond struct {
+	not    bool
+	all    bool
+	index  int    // Stream index; -1 for none.
+	kind   string // Stream type, or "" for any.
+	nth    int    // Nth stream of kind; -1 for none.
+	key    string // Key to match, or "" for none.
+	op     byte   // '=' or '~'
+	val    string
+	valNot bool // Negate nth or key match, but not the kind ("a:!eng").
+}
+
+var selKinds = map[string]string{
+	"v": "video", "a": "audio", "s": "subtitle", "d": "data", "t": "attachment",
+	"video": "video", "audio": "audio", "subtitle": "subtitle", "data": "data", "attachment": "attachment",
+}
+
+// ParseSelector parses a stream selector.
+func ParseSelector(sel string) (Selector, error) {
+	var s Selector
+	if strings.TrimSpace(sel) == "" {
+		return s, fmt.Errorf("wtff.ParseSelector: empty selector")
+	}
+	for _, term := range strings.Split(sel, ",") {
+		var conds []selCond
+		for _, c := range strings.Split(strings.TrimSpace(term), "+") {
+			cond, err := parseCond(strings.TrimSpace(c))
+			if err != nil {
+				return s, fmt.Errorf("wtff.ParseSelector: %q: %w", sel, err)
+			}
+			conds = append(conds, cond)
+		}
+		s.terms = append(s.terms, conds)
+	}
+	return s, nil
+}
+
+func parseCond(c string) (selCond, error) {
+	cond := selCond{index: -1, nth: -1}
+	if strings.HasPrefix(c, "!") {
+		cond.not, c = true, c[1:]
+	}
+	if c == "" {
+		return cond, fmt.Errorf("empty condition")
+	}
+
+	if i := strings.IndexAny(c, "=~"); i > -1 {
+		cond.key, cond.op, cond.val = strings.ToLower(c[:i]), c[i], c[i+1:]
+		switch cond.key {
+		case "":
+			return cond, fmt.Errorf("no key in %q", c)
+		case "language":
+			cond.key = "lang"
+		case "type":
+			k, ok := selKinds[cond.val]
+			if !ok {
+				return cond, fmt.Errorf("unknown stream type: %q", cond.val)
+			}
+			cond.key, cond.kind = "", k
+		}
+		return cond, nil
+	}
+
+	if c == "ALL" {
+		cond.all = true
+		return cond, nil
+	}
+	if n, err := strconv.Atoi(c); err == nil {
+		cond.index = n
+		return cond, nil
+	}
+
+	k, v, hasV := strings.Cut(c, ":")
+	kind, ok := selKinds[k]
+	if !ok {
+		if hasV {
+			return cond, fmt.Errorf("unknown stream type: %q", k)
+		}
+		cond.key, cond.op, cond.val = "lang", '=', c
+		return cond, nil
+	}
+	cond.kind = kind
+	if strings.HasPrefix(v, "!") {
+		cond.valNot, v = true, v[1:]
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		cond.nth = n
+	} else if v != "" {
+		cond.key, cond.op, cond.val = "lang", '=', v
+	}
+	return cond, nil
+}
+
+// Match reports if the stream matches the selector; nth is the position of the
+// stream among streams of the same type, starting at 0.
+func (s Selector) Match(st Stream, nth int) bool {
+	for _, term := range s.terms {
+		ok := true
+		for _, c := range term {
+			if c.match(st, nth) == c.not {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c selCond) match(st Stream, nth int) bool {
+	switch {
+	case c.all:
+		return true
+	case c.index > -1:
+		return st.Index == c.index
+	case c.kind != "" && st.CodecType != c.kind:
+		return false
+	case c.nth > -1:
+		return (nth == c.nth) != c.valNot
+	case c.key == "":
+		return true
+	}
+
+	var have []string
+	switch c.key {
+	case "codec":
+		have = []string{st.CodecName}
+	case "lang":
+		have = []string{st.Lang()}
+	case "disposition":
+		have = st.Disposition.List()
+	default:
+		for k, v := range st.Tags {
+			if strings.EqualFold(k, c.key) {
+				have = append(have, fmt.Sprint(v))
+			}
+		}
+	}
+	for _, h := range have {
+		if c.op == '~' && strings.Contains(strings.ToLower(h), strings.ToLower(c.val)) {
+			return !c.valNot
+		}
+		if c.op == '=' && strings.EqualFold(h, c.val) {
+			return !c.valNot
+		}
+	}
+	return c.valNot
+}
+
+// Select gets all streams matching the selector.
+func (s Streams) Select(sel Selector) Streams {
+	var (
+		found Streams
+		nth   = make(map[string]int)
+	)
+	for _, st := range s {
+		if sel.Match(st, nth[st.CodecType]) {
+			found = append(found, st)
+		}
+		nth[st.CodecType]++
+	}
+	return found
+}
+
+// SelectKind gets all streams of kind matching the selector string; kind can be
+// "" to get streams of any kind.
+func (s Streams) SelectKind(kind, sel string) (Streams, error) {
+	ss, err := ParseSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	var found Streams
+	for _, st := range s.Select(ss) {
+		if kind == "" || st.CodecType == kind {
+			found = append(found, st)
+		}
+	}
+	return found, nil
+}
+
+// Contains reports if the stream with this index is in the list.
+func (s Streams) Contains(index int) bool {
+	for _, st := range s {
+		if st.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// selectOne gets the index of the one stream of kind matching the selector.
+func (s Streams) selectOne(kind, sel string) (int, error) {
+	found, err := s.SelectKind(kind, sel)
+	if err != nil {
+		return -1, err
+	}
+	switch len(found) {
+	case 0:
+		return -1, fmt.Errorf("no %s streams match %q", kind, sel)
+	case 1:
+		return found[0].Index, nil
+	default:
+		return -1, fmt.Errorf("stream %q matches %d %s streams; need exactly one", sel, len(found), kind)
+	}
+}
@@ -0,0 +1,138 @@
+package wtff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	streams := Streams{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac", Disposition: Disposition{Default: 1},
+			Tags: map[string]any{"language": "eng"}},
+		{Index: 2, CodecType: "audio", CodecName: "ac3",
+			Tags: map[string]any{"language": "jpn", "title": "Commentary by X"}},
+		{Index: 3, CodecType: "audio", CodecName: "dts"},
+		{Index: 4, CodecType: "subtitle", CodecName: "subrip", Disposition: Disposition{Forced: 1},
+			Tags: map[string]any{"language": "eng"}},
+		{Index: 5, CodecType: "subtitle", CodecName: "subrip",
+			Tags: map[string]any{"language": "dut"}},
+		{Index: 6, CodecType: "attachment", CodecName: "ttf",
+			Tags: map[string]any{"filename": "font.ttf"}},
+	}
+
+	tests := []struct {
+		sel  string
+		want []int
+	}{
+		// Single conditions.
+		{"3", []int{3}},
+		{"9", nil},
+		{"eng", []int{1, 4}},
+		{"und", []int{0, 3, 6}},
+		{"ALL", []int{0, 1, 2, 3, 4, 5, 6}},
+		{"v", []int{0}},
+		{"a", []int{1, 2, 3}},
+		{"audio", []int{1, 2, 3}},
+		{"s", []int{4, 5}},
+		{"t", []int{6}},
+		{"d", nil},
+		{"a:1", []int{2}},
+		{"a:5", nil},
+		{"a:eng", []int{1}},
+		{"a:!eng", []int{2, 3}},
+		{"a:!1", []int{1, 3}},
+		{"codec=dts", []int{3}},
+		{"CODEC=DTS", []int{3}},
+		{"lang=jpn", []int{2}},
+		{"language=jpn", []int{2}},
+		{"title=Commentary by X", []int{2}},
+		{"title=commentary by x", []int{2}},
+		{"title=commentary", nil},
+		{"title~commentary", []int{2}},
+		{"disposition=forced", []int{4}},
+		{"disposition=default", []int{1}},
+		{"filename=font.ttf", []int{6}},
+		{"filename~FONT", []int{6}},
+		{"type=s", []int{4, 5}},
+
+		// Negation.
+		{"!a", []int{0, 4, 5, 6}},
+		{"!title~commentary", []int{0, 1, 3, 4, 5, 6}},
+		{"!ALL", nil},
+
+		// AND and OR.
+		{"a+!a:0", []int{2, 3}},
+		{"s:eng+!disposition=forced,s:dut", []int{5}},
+		{"a+eng", []int{1}},
+		{"v,s", []int{0, 4, 5}},
+		{" a:0 , s:1 ", []int{1, 5}},
+		{"a:0,1", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			sel, err := ParseSelector(tt.sel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var have []int
+			for _, s := range streams.Select(sel) {
+				have = append(have, s.Index)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("\nhave: %v\nwant: %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSelectorError(t *testing.T) {
+	tests := []struct {
+		sel, wantErr string
+	}{
+		{"", "empty selector"},
+		{"  ", "empty selector"},
+		{"a,", "empty condition"},
+		{"a+", "empty condition"},
+		{"!", "empty condition"},
+		{"x:1", `unknown stream type: "x"`},
+		{"=foo", "no key"},
+		{"~foo", "no key"},
+		{"type=x", `unknown stream type: "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			_, err := ParseSelector(tt.sel)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wrong error\nhave: %v\nwant: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelectKind(t *testing.T) {
+	streams := Streams{
+		{Index: 0, CodecType: "video"},
+		{Index: 1, CodecType: "audio", Tags: map[string]any{"language": "eng"}},
+		{Index: 2, CodecType: "subtitle", Tags: map[string]any{"language": "eng"}},
+		{Index: 3, CodecType: "audio", Tags: map[string]any{"language": "eng"}},
+	}
+	found, err := streams.SelectKind("subtitle", "eng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Index != 2 {
+		t.Errorf("wrong streams: %v", found)
+	}
+
+	if n, err := streams.selectOne("subtitle", "ALL"); err != nil || n != 2 {
+		t.Errorf("wrong stream: %d, %v", n, err)
+	}
+	if _, err := streams.selectOne("audio", "eng"); err == nil || !strings.Contains(err.Error(), "matches 2") {
+		t.Errorf("wrong error: %v", err)
+	}
+	if _, err := streams.selectOne("audio", "jpn"); err == nil || !strings.Contains(err.Error(), "no audio streams") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
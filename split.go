@@ -0,0 +1,204 @@
+package wtff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"zgo.at/zstd/zmap"
+)
+
+// Split the input in to one file per chapter of m, without re-encoding. The
+// output function gets the filename for every chapter; n starts at 1.
+//
+// Every file gets the global tags from m, with the title as "album" (unless
+// there is already an album tag), the chapter title as "title", and the
+// chapter number as "track". The End of the last chapter is set to the end of
+// the file if it's 0. The stream metadata is copied as-is.
+//
+// Because nothing is re-encoded the cuts happen on the nearest keyframe; this
+// is usually accurate for audio, but may be off by a few seconds for video.
+func Split(ctx context.Context, input string, m Meta, output func(n int, c MetaChapter) string) error {
+	if len(m.Chapters) == 0 {
+		return fmt.Errorf("wtff.Split: no chapters")
+	}
+	info, err := Probe(ctx, input)
+	if err != nil {
+		return fmt.Errorf("wtff.Split: %w", err)
+	}
+	chapters := chapterEnds(m.Chapters, info.Format.Duration.Duration)
+
+	tags := make(map[string]string, len(m.Other)+3)
+	for k, v := range m.Other {
+		tags[k] = v
+	}
+	if m.Artist != "" {
+		tags["artist"] = m.Artist
+	}
+	if m.Date != "" {
+		tags["date"] = m.Date
+	}
+	if _, ok := tags["album"]; !ok && m.Title != "" {
+		tags["album"] = m.Title
+	}
+	delete(tags, "track")
+
+	spec := "-metadata"
+	if ts := tagStream(info); ts > -1 {
+		spec = "-metadata:s:" + strconv.Itoa(ts)
+	}
+
+	for i, c := range chapters {
+		start, end := c.StartTime(), c.EndTime()
+		if end <= start {
+			return fmt.Errorf("wtff.Split: chapter %d (%q) ends before it starts", i+1, c.Title)
+		}
+
+		args := []string{
+			"-y",
+			"-ss", Time{Duration: start}.String(),
+			"-i", input,
+			"-to", Time{Duration: end - start}.String(),
+			"-map", "0",
+			"-map_chapters", "-1",
+			"-map_metadata:g", "-1", // Keep stream tags such as the language.
+			"-avoid_negative_ts", "make_zero",
+			"-c", "copy",
+		}
+		for _, k := range zmap.KeysOrdered(tags) {
+			args = append(args, spec, k+"="+tags[k])
+		}
+		args = append(args,
+			spec, "title="+c.Title,
+			spec, "track="+strconv.Itoa(i+1)+"/"+strconv.Itoa(len(chapters)),
+			output(i+1, c))
+
+		_, err := ffmpegProgress(ctx, "wtff.Split", end-start, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitEvery splits the input in to parts with a length of every, without
+// re-encoding. The output function gets the filename for every part; n starts
+// at 1.
+//
+// Video is cut on the last keyframe before every cut point, so parts may be a
+// bit shorter than every; the next part starts where the previous part ended.
+// A part is longer if there is no keyframe within every.
+func SplitEvery(ctx context.Context, input string, every time.Duration, output func(n int) string) error {
+	if every <= 0 {
+		return fmt.Errorf("wtff.SplitEvery: invalid length: %s", every)
+	}
+	info, err := Probe(ctx, input)
+	if err != nil {
+		return fmt.Errorf("wtff.SplitEvery: %w", err)
+	}
+	if info.Format.Duration.Duration <= 0 {
+		return fmt.Errorf("wtff.SplitEvery: can't get duration of %q", input)
+	}
+	return splitParts(ctx, "wtff.SplitEvery", input, info, every, 0, output)
+}
+
+// SplitSize splits the input in to parts of at most size bytes, without
+// re-encoding. The output function gets the filename for every part; n starts
+// at 1.
+//
+// The length of the parts is estimated from the average bitrate; parts that
+// turn out to be too large are written again with a shorter length. As with
+// SplitEvery, video is cut on keyframes.
+func SplitSize(ctx context.Context, input string, size Byte, output func(n int) string) error {
+	info, err := Probe(ctx, input)
+	if err != nil {
+		return fmt.Errorf("wtff.SplitSize: %w", err)
+	}
+	total := info.Format.Duration.Duration
+	if info.Format.Size <= 0 || total <= 0 {
+		return fmt.Errorf("wtff.SplitSize: can't get size or duration of %q", input)
+	}
+	// Leave some room for bitrate variations and container overhead.
+	every := time.Duration(float64(size) / float64(info.Format.Size) * float64(total) * .95)
+	if every < time.Second {
+		return fmt.Errorf("wtff.SplitSize: size %s is too small", size)
+	}
+	return splitParts(ctx, "wtff.SplitSize", input, info, every, size, output)
+}
+
+// splitParts splits input in to parts of every length, cut on the keyframes of
+// the first video stream. If limit is not 0 then parts larger than limit are
+// written again with a shorter length.
+func splitParts(ctx context.Context, op, input string, info ProbeFile, every time.Duration, limit Byte, output func(n int) string) error {
+	total := info.Format.Duration.Duration
+
+	// Every audio packet can be decoded on its own, so audio can be cut
+	// anywhere.
+	var kf []time.Duration
+	if v := info.Streams.videoStream(); v > -1 {
+		var err error
+		kf, err = keyframes(ctx, op, input, info, v, 0, total)
+		if err != nil {
+			return err
+		}
+	}
+	// cut gets the end of a part starting at start that's at most l long.
+	cut := func(start, l time.Duration) time.Duration {
+		end := start + l
+		if end >= total || kf == nil {
+			return min(end, total)
+		}
+		i, _ := slices.BinarySearch(kf, end+1)
+		switch {
+		case i > 0 && kf[i-1] > start:
+			return kf[i-1]
+		case i < len(kf):
+			return kf[i]
+		}
+		return total
+	}
+
+	for n, start := 1, time.Duration(0); start < total; n++ {
+		var (
+			out = output(n)
+			l   = every
+			end = cut(start, l)
+		)
+		for {
+			_, err := ffmpegProgress(ctx, op, end-start,
+				"-y",
+				"-ss", Time{Duration: start}.String(),
+				"-i", input,
+				"-to", Time{Duration: end - start}.String(),
+				"-map", "0",
+				"-avoid_negative_ts", "make_zero",
+				"-c", "copy",
+				out)
+			if err != nil {
+				return err
+			}
+			if limit == 0 || getPlan(ctx) != nil {
+				break
+			}
+
+			st, err := os.Stat(out)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if Byte(st.Size()) <= limit {
+				break
+			}
+			l = time.Duration(float64(end-start) * float64(limit) / float64(st.Size()) * .95)
+			shorter := cut(start, l)
+			if l < time.Second || shorter >= end {
+				return fmt.Errorf("%s: can't make part %d smaller than %s", op, n, limit)
+			}
+			end = shorter
+		}
+		start = end
+	}
+	return nil
+}
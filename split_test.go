@@ -0,0 +1,60 @@
+package wtff
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	m := Meta{
+		Title:  "Album",
+		Artist: "Someone",
+		Other:  map[string]string{"genre": "Jazz", "track": "9"},
+		Chapters: []MetaChapter{
+			{Timebase: [2]int64{1, 1000}, Start: 0, End: 61_500, Title: "One"},
+			{Timebase: [2]int64{1, 1000}, Start: 61_500, Title: "Two"},
+		},
+	}
+	tests := []struct {
+		name, format, streams string
+		want                  []string
+	}{
+		{"mp3", "mp3", `{"index":0,"codec_name":"mp3","codec_type":"audio"}`, []string{
+			"-ss 00:00 -i in -to 01:01.5 -map 0 -map_chapters -1 -map_metadata:g -1 -avoid_negative_ts make_zero -c copy " +
+				"-metadata album=Album -metadata artist=Someone -metadata genre=Jazz -metadata title=One -metadata track=1/2 1.out",
+			"-ss 01:01.5 -i in -to 00:58.5 -map 0 -map_chapters -1 -map_metadata:g -1 -avoid_negative_ts make_zero -c copy " +
+				"-metadata album=Album -metadata artist=Someone -metadata genre=Jazz -metadata title=Two -metadata track=2/2 2.out",
+		}},
+		// Tags are stored in the first audio stream for Ogg; the cover is a
+		// video stream.
+		{"ogg", "ogg", `{"index":0,"codec_name":"theora","codec_type":"video"},{"index":1,"codec_name":"vorbis","codec_type":"audio"}`, []string{
+			"-ss 00:00 -i in -to 01:01.5 -map 0 -map_chapters -1 -map_metadata:g -1 -avoid_negative_ts make_zero -c copy " +
+				"-metadata:s:1 album=Album -metadata:s:1 artist=Someone -metadata:s:1 genre=Jazz -metadata:s:1 title=One -metadata:s:1 track=1/2 1.out",
+			"-ss 01:01.5 -i in -to 00:58.5 -map 0 -map_chapters -1 -map_metadata:g -1 -avoid_negative_ts make_zero -c copy " +
+				"-metadata:s:1 album=Album -metadata:s:1 artist=Someone -metadata:s:1 genre=Jazz -metadata:s:1 title=Two -metadata:s:1 track=2/2 2.out",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &FakeRunner{Output: func(prog string, args []string) (string, string, error) {
+				return `{"format":{"format_name":"` + tt.format + `","duration":"120.000000"},"streams":[` + tt.streams + `]}`, "", nil
+			}}
+			ctx, plan := DryRun(WithRunner(context.Background(), r))
+
+			err := Split(ctx, "in", m, func(n int, c MetaChapter) string { return strconv.Itoa(n) + ".out" })
+			if err != nil {
+				t.Fatal(err)
+			}
+			have := plan.Commands()
+			for i := range have {
+				have[i] = strings.TrimPrefix(have[i], "ffmpeg -hide_banner -v level+warning -y ")
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("\nhave:\n%s\n\nwant:\n%s", strings.Join(have, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
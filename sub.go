@@ -30,6 +30,25 @@ func (s Subs) String() string {
 	return b.String()
 }
 
+// Cut gets the subtitles for a file which only has the keep ranges: lines
+// outside the ranges are removed, and the others are trimmed and shifted. The
+// ranges must be sorted and not overlap.
+func (s Subs) Cut(keep ...Range) Subs {
+	var (
+		zero = time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+		cut  = make(Subs, 0, len(s))
+	)
+	for _, l := range s {
+		start, end, ok := cutSpan(l.Start.Sub(zero), l.End.Sub(zero), keep)
+		if !ok {
+			continue
+		}
+		l.Seq, l.Start, l.End = len(cut)+1, zero.Add(start), zero.Add(end)
+		cut = append(cut, l)
+	}
+	return cut
+}
+
 func (s SubLine) String() string {
 	b := new(strings.Builder)
 	b.Grow(128)
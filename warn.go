@@ -0,0 +1,27 @@
+package wtff
+
+import (
+	"context"
+	"fmt"
+)
+
+type warnKey struct{}
+
+// WithWarning returns a context which makes operations call f for problems
+// that don't stop the operation, such as a cut not being on the exact time
+// that was requested.
+func WithWarning(ctx context.Context, f func(msg string)) context.Context {
+	return context.WithValue(ctx, warnKey{}, f)
+}
+
+func getWarning(ctx context.Context) func(string) {
+	f, _ := ctx.Value(warnKey{}).(func(string))
+	return f
+}
+
+// warnf reports a warning if the context has a warning callback set.
+func warnf(ctx context.Context, format string, a ...any) {
+	if f := getWarning(ctx); f != nil {
+		f(fmt.Sprintf(format, a...))
+	}
+}
@@ -1,78 +1,439 @@
 package wtff
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"zgo.at/zstd/zbyte"
 	"zgo.at/zstd/zfilepath"
 )
 
 // Print all ffmpeg commands to stderr
 var ShowFFCmd = false
 
-// Cut a part and write to output.
+// Cut a part and write to output. Chapters outside the part are removed, and
+// the others are trimmed and shifted.
+//
+// Nothing is re-encoded, so the cut starts on the keyframe before start; a
+// warning is reported if start is not on a keyframe (see WithWarning). Use
+// CutAccurate to cut on the exact time.
 func Cut(ctx context.Context, input, output string, start, stop Time) error {
-	out, err := ffmpeg(ctx,
+	m, err := ReadMeta(ctx, input)
+	if err != nil {
+		return fmt.Errorf("wtff.Cut: %w", err)
+	}
+	keep, err := keepRanges([]Range{{start, Time{Duration: start.Duration + stop.Duration}}}, m.Duration, false)
+	if err != nil {
+		return fmt.Errorf("wtff.Cut: %w", err)
+	}
+	metaTmp, err := cutMeta(ctx, m, keep)
+	if err != nil {
+		return fmt.Errorf("wtff.Cut: %w", err)
+	}
+	defer remove(ctx, metaTmp)
+
+	warnKeyframe(ctx, "wtff.Cut", input, start.Duration)
+	_, err = ffmpegProgress(ctx, "wtff.Cut", stop.Duration,
 		// "-stats",
 		"-ss", start.String(), // Stream before opening
 		"-i", input, // Input
+		"-i", metaTmp,
 		"-to", stop.String(), // Duration
 		"-avoid_negative_ts", "make_zero",
-		"-map_metadata", "0",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
 		"-movflags", "+faststart",
 		"-default_mode", "infer_no_subs",
 		"-c", "copy",
-		output).CombinedOutput()
+		output)
 	if err != nil {
-		return fmt.Errorf("wtff.Probe: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return err
 	}
 	return nil
 }
 
-// Cat all files to the output, without re-encoding.
-func Cat(ctx context.Context, output string, input ...string) error {
-	tmp, err := os.CreateTemp("", "wtff.*")
+// CutAccurate cuts a part and writes it to output like Cut, but cuts on the
+// exact time rather than on the keyframe before it. Chapters are adjusted as
+// with Cut.
+//
+// Only the partial GOPs at the start and end are re-encoded, with the same
+// codec, pixel format, profile, level, and bitrate as far as possible;
+// everything in between is copied. The codec parameters of H.264 and HEVC are
+// repeated in the stream on every keyframe, as the re-encoded parts never have
+// exactly the same parameters as the copied part. This works best with
+// Matroska, as not every format and player deals well with codec parameters
+// changing in the middle of a stream.
+//
+// Files without video are cut as with Cut. This also happens, with a warning,
+// if the video can't be re-encoded to match the original.
+func CutAccurate(ctx context.Context, input, output string, start, stop Time) error {
+	info, err := Probe(ctx, input)
 	if err != nil {
-		return err
+		return fmt.Errorf("wtff.CutAccurate: %w", err)
+	}
+	v := info.Streams.videoStream()
+	if v == -1 {
+		return Cut(ctx, input, output, start, stop)
+	}
+	if err := canEncode(info.Streams[v]); err != nil {
+		warnf(ctx, "wtff.CutAccurate: %s; cutting on the keyframes instead", err)
+		return Cut(ctx, input, output, start, stop)
+	}
+
+	end := start.Duration + stop.Duration
+	if total := info.Format.Duration.Duration; total > 0 && end > total {
+		end = total
 	}
-	metaTmp, err := os.CreateTemp("", "wtff.*")
+	if end <= start.Duration {
+		return fmt.Errorf("wtff.CutAccurate: nothing to cut from %s to %s", start, Time{Duration: end})
+	}
+	kf, err := keyframes(ctx, "wtff.CutAccurate", input, info, v, start.Duration, end)
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		tmp.Close()
-		os.Remove(tmp.Name())
-		os.Remove(metaTmp.Name())
-	}()
+	// Copy from the first to the last keyframe in the range, and re-encode
+	// everything before and after that.
+	type part struct {
+		start, end time.Duration
+		encode     bool
+	}
+	var (
+		parts  []part
+		k1, k2 = time.Duration(-1), time.Duration(-1)
+	)
+	for _, k := range kf {
+		if k >= start.Duration && k <= end {
+			if k1 == -1 {
+				k1 = k
+			}
+			k2 = k
+		}
+	}
+	if k1 == -1 {
+		parts = append(parts, part{start.Duration, end, true})
+	} else {
+		if start.Duration < k1 {
+			parts = append(parts, part{start.Duration, k1, true})
+		}
+		if k2 > k1 {
+			parts = append(parts, part{k1, k2, false})
+		}
+		if k2 < end {
+			parts = append(parts, part{k2, end, true})
+		}
+	}
+	if len(parts) == 1 && !parts[0].encode {
+		return Cut(ctx, input, output, start, stop)
+	}
+
+	m, err := ReadMeta(ctx, input)
+	if err != nil {
+		return fmt.Errorf("wtff.CutAccurate: %w", err)
+	}
+	metaTmp, err := cutMeta(ctx, m, []Range{{start, Time{Duration: end}}})
+	if err != nil {
+		return fmt.Errorf("wtff.CutAccurate: %w", err)
+	}
+
+	_, ext := zfilepath.SplitExt(input)
+	var (
+		list  = new(strings.Builder)
+		files = append(make([]string, 0, len(parts)+2), metaTmp)
+	)
+	defer func() { remove(ctx, files...) }()
+	for _, p := range parts {
+		tmp, err := tmpPart(ctx, filepath.Dir(output), ext)
+		if err != nil {
+			return fmt.Errorf("wtff.CutAccurate: %w", err)
+		}
+		files = append(files, tmp)
+
+		args := []string{
+			"-y",
+			"-ss", Time{Duration: p.start}.String(),
+			"-i", input,
+			"-to", Time{Duration: p.end - p.start}.String(),
+			"-map", "0",
+			"-map_chapters", "-1",
+			"-avoid_negative_ts", "make_zero",
+			"-c", "copy",
+		}
+		args = append(args, inBandArgs(info.Streams[v])...)
+		if p.encode {
+			args = append(args, encodeArgs(info.Streams[v])...)
+		}
+		_, err = ffmpegProgress(ctx, "wtff.CutAccurate", p.end-p.start, append(args, tmp)...)
+		if err != nil {
+			return err
+		}
+
+		abs, err := filepath.Abs(tmp)
+		if err != nil {
+			return fmt.Errorf("wtff.CutAccurate: %w", err)
+		}
+		fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(abs, `'`, `'\''`))
+	}
+
+	listTmp, err := writeTemp(ctx, "", "wtff.*", list.String())
+	if err != nil {
+		return fmt.Errorf("wtff.CutAccurate: %w", err)
+	}
+	files = append(files, listTmp)
+
+	_, err = ffmpegProgress(ctx, "wtff.CutAccurate", end-start.Duration,
+		"-f", "concat",
+		"-safe", "0", // Trust filenames
+		"-i", listTmp,
+		"-i", metaTmp,
+		"-map", "0",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
+		"-movflags", "+faststart",
+		"-default_mode", "infer_no_subs",
+		"-c", "copy",
+		output)
+	return err
+}
+
+// encodeArgs gets the ffmpeg flags to re-encode the video or audio stream with
+// the same codec and parameters, as far as possible. The flags are for the
+// output stream with the same index as s.
+func encodeArgs(s Stream) []string {
+	var (
+		n   = strconv.Itoa(s.Index)
+		enc = s.CodecName
+	)
+	switch s.CodecName {
+	case "h264":
+		enc = "libx264"
+	case "hevc":
+		enc = "libx265"
+	case "vp8":
+		enc = "libvpx"
+	case "vp9":
+		enc = "libvpx-vp9"
+	case "av1":
+		enc = "libsvtav1"
+	case "mp3":
+		enc = "libmp3lame"
+	case "opus":
+		enc = "libopus"
+	case "vorbis":
+		enc = "libvorbis"
+	}
+
+	args := []string{"-c:" + n, enc}
+	switch s.CodecType {
+	case "video":
+		if s.PixFmt != "" {
+			args = append(args, "-pix_fmt:"+n, s.PixFmt)
+		}
+		if p := encodeProfile(s); p != "" {
+			args = append(args, "-profile:"+n, p)
+		}
+		// ffprobe reports the level as 10×level for H.264 and 30×level for
+		// HEVC; libx265 doesn't have a -level flag.
+		if s.Level > 0 {
+			switch s.CodecName {
+			case "h264":
+				args = append(args, "-level:"+n, strconv.FormatFloat(float64(s.Level)/10, 'f', 1, 64))
+			case "hevc":
+				args = append(args, "-x265-params:"+n, "level-idc="+strconv.FormatFloat(float64(s.Level)/30, 'f', 1, 64))
+			}
+		}
+	case "audio":
+		if s.SampleRate != "" {
+			args = append(args, "-ar:"+n, s.SampleRate)
+		}
+		if s.Channels > 0 {
+			args = append(args, "-ac:"+n, strconv.Itoa(int(s.Channels)))
+		}
+	}
+	if s.BitRate != "" {
+		args = append(args, "-b:"+n, s.BitRate)
+	}
+	return args
+}
+
+// encodeProfile gets the encoder profile for the stream's profile, or "" if
+// it's not known.
+func encodeProfile(s Stream) string {
+	// "High 4:2:2" → "high422", "Main 10" → "main10", etc.
+	profile := strings.NewReplacer(" ", "", ":", "", "constrained", "", "predictive", "").Replace(strings.ToLower(s.Profile))
+	if slices.Contains([]string{"baseline", "main", "high", "high10", "high422", "high444", "main10", "main12"}, profile) {
+		return profile
+	}
+	return ""
+}
+
+// canEncode reports an error if the video stream can't be re-encoded with
+// parameters close enough to the original to join it with copied parts.
+func canEncode(s Stream) error {
+	switch s.CodecName {
+	case "vp8", "vp9", "av1":
+		return nil
+	case "h264", "hevc":
+		if encodeProfile(s) == "" {
+			return fmt.Errorf("can't re-encode %s with profile %q", s.CodecName, s.Profile)
+		}
+		if s.PixFmt == "" {
+			return fmt.Errorf("can't re-encode %s with unknown pixel format", s.CodecName)
+		}
+		return nil
+	}
+	return fmt.Errorf("can't re-encode %s video", s.CodecName)
+}
+
+// inBandArgs gets the ffmpeg flags to repeat the codec parameters (the H.264
+// and HEVC SPS and PPS) before every keyframe, rather than storing them only
+// once in the container. This is needed when joining parts that were encoded
+// with different parameters, as the concat demuxer uses the parameters from
+// the first part for all parts.
+func inBandArgs(s Stream) []string {
+	switch s.CodecName {
+	case "h264", "hevc":
+		return []string{"-bsf:" + strconv.Itoa(s.Index), "dump_extra"}
+	}
+	return nil
+}
+
+// warnKeyframe reports a warning for every time in at that's not on a keyframe
+// of the video, as cuts without re-encoding start on the keyframe before it.
+func warnKeyframe(ctx context.Context, op, input string, at ...time.Duration) {
+	if getWarning(ctx) == nil {
+		return
+	}
+	info, err := Probe(ctx, input)
+	if err != nil {
+		return
+	}
+	v := info.Streams.videoStream()
+	if v == -1 {
+		return
+	}
+	for _, t := range at {
+		if t == 0 {
+			continue
+		}
+		kf, err := keyframes(ctx, op, input, info, v, t, t)
+		if err != nil {
+			return
+		}
+		cut := time.Duration(-1)
+		for _, k := range kf {
+			if k <= t {
+				cut = k
+			}
+		}
+		if cut > -1 && cut != t {
+			warnf(ctx, "%s: %s is not on a keyframe; cutting at %s instead", op, Time{Duration: t}, Time{Duration: cut})
+		}
+	}
+}
+
+// Cat all files to the output, without re-encoding. Every file is added as a
+// chapter, named after the filename.
+//
+// The files must have the same streams with the same codec parameters, or the
+// output will be broken; use CheckConcat to check this first.
+//
+// This is the same as CatWith with the default options.
+func Cat(ctx context.Context, output string, input ...string) error {
+	return CatWith(ctx, output, CatOptions{}, input...)
+}
+
+// CatOptions are options for CatWith.
+type CatOptions struct {
+	// Keep the chapters of the inputs, shifted to the start of the input in the
+	// output. Inputs without chapters get one chapter for the entire file.
+	MergeChapters bool
+
+	// Prefix the title of merged chapters with the chapter title of the file,
+	// as "file title - chapter title".
+	NestChapters bool
+
+	// ChapterTitle gets the chapter title for every input; n starts at 1 and m
+	// is the input's metadata. The default is to use the filename without
+	// extension.
+	ChapterTitle func(n int, input string, m Meta) string
+
+	// Join the text subtitles of all inputs, also if only some inputs have
+	// subtitles. Subtitles are matched by their order in the inputs, and
+	// converted to SRT (or mov_text for MP4). Other subtitles, such as
+	// bitmap subtitles, are dropped.
+	Subs bool
+
+	// Convert the inputs that can't be concatenated as-is to match Target.
+	//
+	// The output has the first video and audio stream of the first input; all
+	// other streams are dropped. The video of inputs with a different codec,
+	// profile, pixel format, resolution, or frame rate (if set in the target)
+	// is re-encoded, and scaled and padded to the target resolution. The audio
+	// of inputs with a different codec, sample rate, or channels is
+	// re-encoded, and inputs without audio get silence. Everything else is
+	// copied. Converted inputs are written to temporary files next to the
+	// output.
+	Normalize bool
+	Target    CatTarget
+}
 
+// CatWith concatenates all files to the output like Cat, with the given
+// options.
+func CatWith(ctx context.Context, output string, opt CatOptions, input ...string) error {
+	if len(input) == 0 {
+		return fmt.Errorf("wtff.Cat: no input files")
+	}
 	var (
-		m Meta
-		l time.Duration
+		files = input
+		args  []string
 	)
-	if len(input) == 1 {
-		m, err = ReadMeta(ctx, input[0])
+	if opt.Normalize {
+		var (
+			tmp []string
+			err error
+		)
+		files, tmp, args, err = normalize(ctx, output, opt.Target, input)
+		defer remove(ctx, tmp...)
 		if err != nil {
 			return err
 		}
-		abs, err := filepath.Abs(input[0])
+	}
+	return cat(ctx, output, files, input, opt, args...)
+}
+
+// cat concatenates files to output. The chapters titles and durations are taken
+// from names, which is the list of original filenames if files are temporary
+// files. Any extra ffmpeg flags in args are added before the output.
+func cat(ctx context.Context, output string, files, names []string, opt CatOptions, args ...string) error {
+	var (
+		m       Meta
+		l       time.Duration
+		list    = new(strings.Builder)
+		offsets = make([]time.Duration, 0, len(files))
+		err     error
+	)
+	if len(files) == 1 {
+		m, err = ReadMeta(ctx, names[0])
 		if err != nil {
 			return err
 		}
-		_, err = fmt.Fprintf(tmp, "file '%s'\n", strings.ReplaceAll(abs, `'`, `'\''`))
+		abs, err := filepath.Abs(files[0])
 		if err != nil {
 			return err
 		}
+		fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(abs, `'`, `'\''`))
+		l = probeDuration(ctx, names[0])
+		offsets = append(offsets, 0)
 	} else {
-		for _, i := range input {
-			p, err := Probe(ctx, i)
+		for n, i := range files {
+			p, err := Probe(ctx, names[n])
 			if err != nil {
 				return err
 			}
@@ -81,144 +442,218 @@ func Cat(ctx context.Context, output string, input ...string) error {
 			if err != nil {
 				return err
 			}
-			_, err = fmt.Fprintf(tmp, "file '%s'\n", strings.ReplaceAll(i, `'`, `'\''`))
+			fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(i, `'`, `'\''`))
+
+			ch, err := catChapters(ctx, n, names[n], p.Format.Duration.Duration, opt)
 			if err != nil {
 				return err
 			}
-			t, _ := zfilepath.SplitExt(filepath.Base(i))
-			m.Chapters = append(m.Chapters, MetaChapter{
-				Timebase: [2]int64{1, 1000},
-				Start:    l.Milliseconds(),
-				End:      (l + p.Format.Duration.Duration).Milliseconds(),
-				Title:    t,
-			})
+			for _, c := range ch {
+				c.Start += l.Milliseconds()
+				c.End += l.Milliseconds()
+				m.Chapters = append(m.Chapters, c)
+			}
+			offsets = append(offsets, l)
 			l += p.Format.Duration.Duration
 		}
 	}
-	err = tmp.Close()
-	if err != nil {
-		return err
-	}
 
-	_, err = metaTmp.WriteString(m.String())
+	listTmp, err := writeTemp(ctx, "", "wtff.*", list.String())
 	if err != nil {
 		return err
 	}
-	err = metaTmp.Close()
+	metaTmp, err := writeTemp(ctx, "", "wtff.*", m.String())
 	if err != nil {
+		remove(ctx, listTmp)
 		return err
 	}
+	defer remove(ctx, listTmp, metaTmp)
 
-	// TODO: H.264 is buggy: https://trac.ffmpeg.org/ticket/9893
-	out, err := ffmpeg(ctx,
+	inputs := []string{
 		"-f", "concat",
 		"-safe", "0", // Trust filenames
-		"-i", tmp.Name(),
-		"-i", metaTmp.Name(),
+		"-i", listTmp,
+		"-i", metaTmp,
+	}
+	if opt.Subs && len(files) > 1 {
+		subs, subArgs, err := catSubs(ctx, output, names, offsets)
+		defer remove(ctx, subs...)
+		if err != nil {
+			return err
+		}
+		// The inputs may have a different number of subtitle streams, so
+		// only copy video and audio from the concat demuxer.
+		for _, s := range subs {
+			inputs = append(inputs, "-i", s)
+		}
+		if !slices.Contains(args, "-map") {
+			args = append(args, "-map", "0:v?", "-map", "0:a?")
+		}
+		args = append(args, subArgs...)
+	}
+
+	// TODO: H.264 is buggy: https://trac.ffmpeg.org/ticket/9893
+	args = append(append(inputs,
 		"-map_chapters", "1",
 		"-map_metadata", "1",
 		"-c", "copy",
-		output).CombinedOutput()
+	), args...)
+	_, err = ffmpegProgress(ctx, "wtff.Cat", l, append(args, output)...)
 	if err != nil {
-		return fmt.Errorf("wtff.Probe: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return err
 	}
 	return nil
 }
 
-func SubAdd(ctx context.Context, input, subFile, lang string) error {
-	tmp, err := tmpFile(input)
-	if err != nil {
-		return fmt.Errorf("wtff.SubAdd: %w", err)
-	}
-	defer os.Remove(tmp.Name())
-
-	info, err := Probe(ctx, input)
-	if err != nil {
-		return fmt.Errorf("wtff.SubAdd: %w", err)
-	}
-	codec := "srt"
-	if info.Format.FormatName == "mov,mp4,m4a,3gp,3g2,mj2" {
-		codec = "mov_text"
-	}
-	var n int
-	for _, s := range info.Streams {
-		if s.Subtitle() {
-			n++
+// catChapters gets the chapters for the nth input of Cat, relative to the start
+// of the input.
+func catChapters(ctx context.Context, n int, input string, dur time.Duration, opt CatOptions) ([]MetaChapter, error) {
+	var (
+		m   Meta
+		err error
+	)
+	if opt.MergeChapters || opt.ChapterTitle != nil {
+		m, err = ReadMeta(ctx, input)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	out, err := ffmpeg(ctx,
-		"-y",
-		"-i", input,
-		"-i", subFile,
-		"-map", "0",
-		"-map", "1",
-		"-c", "copy",
-		"-c:s", codec,
-		"-metadata:s:s:"+strconv.Itoa(n), "language="+lang,
-		tmp.Name()).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("wtff.SubAdd: %w: %s", err, zbyte.ElideLeft(out, 500))
+	title, _ := zfilepath.SplitExt(filepath.Base(input))
+	if opt.ChapterTitle != nil {
+		title = opt.ChapterTitle(n+1, input, m)
 	}
-	err = os.Rename(tmp.Name(), input)
-	if err != nil {
-		return fmt.Errorf("wtff.SubAdd: %w", err)
-	}
-	return nil
-}
-
-func SubRm(ctx context.Context, input, stream string) error {
-	tmp, err := tmpFile(input)
-	if err != nil {
-		return fmt.Errorf("wtff.SubRm: %w", err)
+	if !opt.MergeChapters || len(m.Chapters) == 0 {
+		return []MetaChapter{{Timebase: [2]int64{1, 1000}, End: dur.Milliseconds(), Title: title}}, nil
 	}
-	defer os.Remove(tmp.Name())
 
-	info, err := Probe(ctx, input)
-	if err != nil {
-		return fmt.Errorf("wtff.SubRm: %w", err)
-	}
+	chapters := make([]MetaChapter, 0, len(m.Chapters))
+	for _, c := range chapterEnds(m.Chapters, dur) {
+		start, end := c.StartTime(), min(c.EndTime(), dur)
+		if end <= start {
+			continue
+		}
+		t := c.Title
+		if opt.NestChapters && t == "" {
+			t = title
+		} else if opt.NestChapters {
+			t = title + " - " + t
+		}
+		chapters = append(chapters, MetaChapter{
+			Timebase: [2]int64{1, 1000},
+			Start:    start.Milliseconds(),
+			End:      end.Milliseconds(),
+			Title:    t,
+		})
+	}
+	return chapters, nil
+}
 
-	args := []string{
-		"-y",
-		"-i", input,
-		"-map", "0:v",
-		"-map", "0:a",
-		//"-map_metadata",
-	}
-	if stream != "ALL" {
-		rm := info.Streams.Find("subtitle", stream)
-		if rm == -1 {
-			return fmt.Errorf("stream %q not found or not a subtitle", stream)
+// catSubs joins the text subtitles of all inputs, shifted by the offsets, to
+// temporary SRT files. It returns the files and the ffmpeg flags to map them,
+// assuming they're added as inputs after the concat list and metadata file.
+func catSubs(ctx context.Context, output string, input []string, offsets []time.Duration) ([]string, []string, error) {
+	var (
+		tracks []Subs
+		first  []Stream // First stream of every track, for the metadata.
+		zero   = time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+	for n, in := range input {
+		info, err := Probe(ctx, in)
+		if err != nil {
+			return nil, nil, err
 		}
+		var t int
 		for _, s := range info.Streams {
-			if s.Subtitle() && s.Index != rm {
-				args = append(args, "-map", "0:"+strconv.Itoa(s.Index))
+			if !s.Subtitle() {
+				continue
+			}
+			switch s.CodecName {
+			case "subrip", "srt", "ass", "ssa", "mov_text", "webvtt", "text":
+			default:
+				warnf(ctx, "wtff.Cat: %q: skipping %s subtitle stream %d; only text subtitles can be joined", in, s.CodecName, s.Index)
+				continue
+			}
+
+			// Always run, even in dry-run mode.
+			out, err := run(ctx, "wtff.Cat", nil, "ffmpeg",
+				"-hide_banner", "-v", "level+error",
+				"-i", in,
+				"-map", "0:"+strconv.Itoa(s.Index),
+				"-f", "srt", "-")
+			if err != nil {
+				return nil, nil, err
+			}
+			subs, err := ParseSRT(string(out))
+			if err != nil {
+				return nil, nil, fmt.Errorf("wtff.Cat: %q: %w", in, err)
+			}
+
+			if t == len(tracks) {
+				tracks, first = append(tracks, nil), append(first, s)
+			}
+			for _, l := range subs {
+				l.Start = zero.Add(l.Start.Sub(zero) + offsets[n])
+				l.End = zero.Add(l.End.Sub(zero) + offsets[n])
+				l.Seq = len(tracks[t]) + 1
+				tracks[t] = append(tracks[t], l)
 			}
+			t++
 		}
 	}
-	args = append(args, "-c", "copy", tmp.Name())
 
-	out, err := ffmpeg(ctx, args...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("wtff.SubRm: %w: %s", err, zbyte.ElideLeft(out, 500))
+	codec := "srt"
+	switch _, ext := zfilepath.SplitExt(output); strings.ToLower(ext) {
+	case "mp4", "m4v", "mov":
+		codec = "mov_text"
 	}
-	err = os.Rename(tmp.Name(), input)
-	if err != nil {
-		return fmt.Errorf("wtff.SubRm: %w", err)
+	var (
+		files = make([]string, 0, len(tracks))
+		args  = make([]string, 0, len(tracks)*8)
+	)
+	for t, subs := range tracks {
+		tmp, err := writeTemp(ctx, "", "wtff.*.srt", subs.String())
+		if err != nil {
+			return files, nil, fmt.Errorf("wtff.Cat: %w", err)
+		}
+		files = append(files, tmp)
+
+		n := strconv.Itoa(t)
+		args = append(args, "-map", strconv.Itoa(t+2)+":0", "-c:s:"+n, codec,
+			"-metadata:s:s:"+n, "language="+first[t].Lang())
+		if title, ok := first[t].Tags["title"].(string); ok && title != "" {
+			args = append(args, "-metadata:s:s:"+n, "title="+title)
+		}
 	}
-	return nil
+	return files, args, nil
+}
+
+// SubAdd adds a subtitle from subFile, with the optional language and
+// dispositions.
+func SubAdd(ctx context.Context, input, subFile, lang string, disposition ...string) error {
+	e := Edit(input).AddSub(subFile, lang, disposition...)
+	e.op = "wtff.SubAdd"
+	return e.Write(ctx, input)
+}
+
+// SubRm removes all subtitles matching the selector; see Selector for the
+// syntax.
+func SubRm(ctx context.Context, input, stream string) error {
+	e := Edit(input).RemoveSub(stream)
+	e.op = "wtff.SubRm"
+	return e.Write(ctx, input)
 }
 
+// SubSave saves the one subtitle matching the selector to output.
 func SubSave(ctx context.Context, input, stream, output string, overwrite bool) error {
 	info, err := Probe(ctx, input)
 	if err != nil {
 		return fmt.Errorf("wtff.SubSave: %w", err)
 	}
 
-	n := info.Streams.Find("subtitle", stream)
-	if n == -1 {
-		return fmt.Errorf("stream %q not found or not a subtitle", stream)
+	n, err := info.Streams.selectOne("subtitle", stream)
+	if err != nil {
+		return fmt.Errorf("wtff.SubSave: %w", err)
 	}
 
 	args := []string{"-i", input, "-map", "0:" + strconv.Itoa(n), output}
@@ -226,144 +661,259 @@ func SubSave(ctx context.Context, input, stream, output string, overwrite bool)
 		args = append(args, "-y")
 	}
 
-	out, err := ffmpeg(ctx, args...).CombinedOutput()
+	_, err = ffmpeg(ctx, "wtff.SubSave", args...)
 	if err != nil {
-		return fmt.Errorf("wtff.SubSave: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return err
 	}
 
 	return nil
 }
 
-func AudioAdd(ctx context.Context, input, audioFile, lang, title string) error {
-	tmp, err := tmpFile(input)
-	if err != nil {
-		return fmt.Errorf("wtff.AudioAdd: %w", err)
-	}
-	defer os.Remove(tmp.Name())
+// AudioAdd adds an audio track from audioFile, with the optional language,
+// title, and dispositions.
+func AudioAdd(ctx context.Context, input, audioFile, lang, title string, disposition ...string) error {
+	e := Edit(input).AddAudio(audioFile, lang, title, disposition...)
+	e.op = "wtff.AudioAdd"
+	return e.Write(ctx, input)
+}
 
+// AudioRm removes all audio tracks matching the selector; see Selector for the
+// syntax.
+func AudioRm(ctx context.Context, input, stream string) error {
+	e := Edit(input).RemoveAudio(stream)
+	e.op = "wtff.AudioRm"
+	return e.Write(ctx, input)
+}
+
+// AudioSave saves the one audio track matching the selector to output.
+func AudioSave(ctx context.Context, input, stream, output string) error {
 	info, err := Probe(ctx, input)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioAdd: %w", err)
+		return fmt.Errorf("wtff.AudioSave: %w", err)
 	}
-	var n int
-	for _, s := range info.Streams {
-		if s.Audio() {
-			n++
-		}
+
+	n, err := info.Streams.selectOne("audio", stream)
+	if err != nil {
+		return fmt.Errorf("wtff.AudioSave: %w", err)
 	}
 
-	// TODO: look into -shortest and -apad
-	out, err := ffmpeg(ctx,
-		"-y",
+	_, err = ffmpeg(ctx, "wtff.AudioSave",
 		"-i", input,
-		"-i", audioFile,
-		"-map", "0",
-		"-map", "1",
 		"-c", "copy",
-		"-metadata:s:a:"+strconv.Itoa(n), "language="+lang,
-		"-metadata:s:a:"+strconv.Itoa(n), "title="+title,
-		tmp.Name()).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("wtff.AudioAdd: %w: %s", err, zbyte.ElideLeft(out, 500))
-	}
-	err = os.Rename(tmp.Name(), input)
+		"-map", "0:"+strconv.Itoa(n),
+		output)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioAdd: %w", err)
+		return err
 	}
+
 	return nil
 }
 
-func AudioRm(ctx context.Context, input, stream string) error {
-	tmp, err := tmpFile(input)
+// probeDuration gets the duration of file if a progress callback is set.
+func probeDuration(ctx context.Context, file string) time.Duration {
+	if getProgress(ctx) == nil {
+		return 0
+	}
+	p, err := Probe(ctx, file)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioRm: %w", err)
+		return 0
 	}
-	defer os.Remove(tmp.Name())
+	return p.Format.Duration.Duration
+}
 
-	info, err := Probe(ctx, input)
+// Range is a time range.
+type Range struct{ Start, End Time }
+
+// CutRanges cuts all ranges from input and joins them in output, without
+// re-encoding. If except is true then everything except the ranges is kept.
+//
+// Chapters are adjusted: chapters that are cut completely are removed, and the
+// others are trimmed and shifted. Because nothing is re-encoded the cuts happen
+// on the keyframe before the start of every range; a warning is reported if
+// this is not the exact time (see WithWarning).
+func CutRanges(ctx context.Context, input, output string, except bool, ranges ...Range) error {
+	if len(ranges) == 0 {
+		return fmt.Errorf("wtff.CutRanges: no ranges")
+	}
+	m, err := ReadMeta(ctx, input)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioRm: %w", err)
+		return fmt.Errorf("wtff.CutRanges: %w", err)
+	}
+	keep, err := keepRanges(ranges, m.Duration, except)
+	if err != nil {
+		return fmt.Errorf("wtff.CutRanges: %w", err)
 	}
 
-	args := []string{
-		"-y",
-		"-i", input,
-		"-map", "0:v",
-		"-map", "0:s",
+	abs, err := filepath.Abs(input)
+	if err != nil {
+		return fmt.Errorf("wtff.CutRanges: %w", err)
 	}
-	if stream != "ALL" {
-		rm := info.Streams.Find("audio", stream)
-		if rm == -1 {
-			return fmt.Errorf("stream %q not found or not a audio track", stream)
-		}
-		for _, s := range info.Streams {
-			if s.Audio() && s.Index != rm {
-				args = append(args, "-map", "0:"+strconv.Itoa(s.Index))
-			}
-		}
+	var (
+		list = new(strings.Builder)
+		l    time.Duration
+	)
+	for _, r := range keep {
+		fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(abs, `'`, `'\''`))
+		fmt.Fprintf(list, "inpoint %s\n", fmtSeconds(r.Start.Duration))
+		fmt.Fprintf(list, "outpoint %s\n", fmtSeconds(r.End.Duration))
+		l += r.End.Duration - r.Start.Duration
+	}
+	at := make([]time.Duration, 0, len(keep))
+	for _, r := range keep {
+		at = append(at, r.Start.Duration)
 	}
-	args = append(args, "-c", "copy", tmp.Name())
+	warnKeyframe(ctx, "wtff.CutRanges", input, at...)
 
-	out, err := ffmpeg(ctx, args...).CombinedOutput()
+	listTmp, err := writeTemp(ctx, "", "wtff.*", list.String())
 	if err != nil {
-		return fmt.Errorf("wtff.AudioRm: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return fmt.Errorf("wtff.CutRanges: %w", err)
 	}
-	err = os.Rename(tmp.Name(), input)
+	metaTmp, err := cutMeta(ctx, m, keep)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioRm: %w", err)
+		remove(ctx, listTmp)
+		return fmt.Errorf("wtff.CutRanges: %w", err)
 	}
-	return nil
+	defer remove(ctx, listTmp, metaTmp)
+
+	_, err = ffmpegProgress(ctx, "wtff.CutRanges", l,
+		"-f", "concat",
+		"-safe", "0", // Trust filenames
+		"-i", listTmp,
+		"-i", metaTmp,
+		"-map", "0",
+		"-map_chapters", "1",
+		"-map_metadata", "1",
+		"-movflags", "+faststart",
+		"-c", "copy",
+		output)
+	return err
 }
 
-func AudioSave(ctx context.Context, input, stream, output string) error {
-	info, err := Probe(ctx, input)
+// CutSubs cuts the ranges from the SRT file subFile and writes the re-timed
+// subtitles to output, to go with a file written with Cut or CutRanges.
+func CutSubs(ctx context.Context, subFile, output string, except bool, ranges ...Range) error {
+	if len(ranges) == 0 {
+		return fmt.Errorf("wtff.CutSubs: no ranges")
+	}
+	data, err := os.ReadFile(subFile)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioSave: %w", err)
+		return fmt.Errorf("wtff.CutSubs: %w", err)
 	}
-
-	n := info.Streams.Find("audio", stream)
-	if n == -1 {
-		return fmt.Errorf("stream %q not found or not a audio track", stream)
+	subs, err := ParseSRT(string(data))
+	if err != nil {
+		return fmt.Errorf("wtff.CutSubs: %w", err)
 	}
 
-	out, err := ffmpeg(ctx,
-		"-i", input,
-		"-c", "copy",
-		"-map", "0:"+strconv.Itoa(n),
-		output).CombinedOutput()
+	// The length of the video isn't known, but everything after the last
+	// subtitle or range doesn't matter.
+	var total time.Duration
+	if except {
+		for _, r := range ranges {
+			total = max(total, r.End.Duration)
+		}
+		for _, l := range subs {
+			total = max(total, l.End.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)))
+		}
+	}
+	keep, err := keepRanges(ranges, total, except)
 	if err != nil {
-		return fmt.Errorf("wtff.AudioSave: %w: %s", err, zbyte.ElideLeft(out, 500))
+		return fmt.Errorf("wtff.CutSubs: %w", err)
 	}
 
+	tmp, err := writeTemp(ctx, filepath.Dir(output), "wtff-*.srt", subs.Cut(keep...).String())
+	if err != nil {
+		return fmt.Errorf("wtff.CutSubs: %w", err)
+	}
+	err = rename(ctx, tmp, output)
+	if err != nil {
+		remove(ctx, tmp)
+		return fmt.Errorf("wtff.CutSubs: %w", err)
+	}
 	return nil
 }
 
-func tmpFile(path string) (*os.File, error) {
-	base, ext := zfilepath.SplitExt(path)
-	return os.CreateTemp(filepath.Dir(path), filepath.Base(base)+"-wtff-meta-*."+ext)
-}
+// keepRanges sorts the ranges and checks they don't overlap. If except is true
+// it returns all the ranges between them, up to total.
+func keepRanges(ranges []Range, total time.Duration, except bool) ([]Range, error) {
+	if except && total <= 0 {
+		return nil, fmt.Errorf("can't remove ranges: duration is unknown")
+	}
+	ranges = slices.Clone(ranges)
+	slices.SortFunc(ranges, func(a, b Range) int { return cmp.Compare(a.Start.Duration, b.Start.Duration) })
+	for i, r := range ranges {
+		if total > 0 && r.End.Duration > total {
+			ranges[i].End.Duration, r.End.Duration = total, total
+		}
+		if r.End.Duration <= r.Start.Duration {
+			return nil, fmt.Errorf("range %s to %s: end is before start", r.Start, r.End)
+		}
+		if i > 0 && r.Start.Duration < ranges[i-1].End.Duration {
+			return nil, fmt.Errorf("range %s to %s overlaps with %s to %s", r.Start, r.End, ranges[i-1].Start, ranges[i-1].End)
+		}
+	}
+	if !except {
+		return ranges, nil
+	}
 
-func ffmpeg(ctx context.Context, args ...string) *exec.Cmd {
-	args = append([]string{"-hide_banner", "-v", "warning"}, args...)
-	if ShowFFCmd {
-		qa := make([]string, 0, len(args))
-		for _, a := range args {
-			qa = append(qa, shellQuote(a))
+	var (
+		keep  []Range
+		start time.Duration
+	)
+	for _, r := range ranges {
+		if r.Start.Duration > start {
+			keep = append(keep, Range{Time{Duration: start}, r.Start})
 		}
-		fmt.Fprintf(os.Stderr, "ffmpeg "+strings.Join(args, " ")+"\n")
+		start = r.End.Duration
+	}
+	if start < total {
+		keep = append(keep, Range{Time{Duration: start}, Time{Duration: total}})
+	}
+	if len(keep) == 0 {
+		return nil, fmt.Errorf("nothing left after removing all ranges")
 	}
-	return exec.CommandContext(ctx, "ffmpeg", args...)
+	return keep, nil
 }
 
-func shellQuote(s string) string {
-	if len(s) == 0 {
-		return "''"
-	}
-	if !strings.ContainsAny(s, "\\'\"`${[|&;<>()*?! \t\n") && s[0] != '~' {
-		return s
+// cutMeta writes m to a temporary file, with the chapters adjusted for a file
+// which only has the keep ranges.
+func cutMeta(ctx context.Context, m Meta, keep []Range) (string, error) {
+	m.Streams, m.Chapters = nil, cutChapters(chapterEnds(m.Chapters, m.Duration), keep)
+	return writeTemp(ctx, "", "wtff.*", m.String())
+}
+
+// cutChapters adjusts the chapters for a file which only has the keep ranges.
+func cutChapters(chapters []MetaChapter, keep []Range) []MetaChapter {
+	var cut []MetaChapter
+	for _, c := range chapters {
+		start, end, ok := cutSpan(c.StartTime(), c.EndTime(), keep)
+		if ok {
+			cut = append(cut, MetaChapter{
+				Timebase: [2]int64{1, 1000},
+				Start:    start.Milliseconds(),
+				End:      end.Milliseconds(),
+				Title:    c.Title,
+			})
+		}
 	}
-	if strings.Contains(s, "'") && !strings.ContainsAny("\\\"$`", s) {
-		return `"` + s + `"`
+	return cut
+}
+
+// cutSpan gets the start and end of the span in a file which only has the keep
+// ranges. It returns false if no part of the span is kept.
+func cutSpan(start, end time.Duration, keep []Range) (time.Duration, time.Duration, bool) {
+	var (
+		newStart, newEnd = time.Duration(-1), time.Duration(-1)
+		offset           time.Duration
+	)
+	for _, r := range keep {
+		s, e := max(start, r.Start.Duration), min(end, r.End.Duration)
+		if e > s {
+			if newStart == -1 {
+				newStart = offset + s - r.Start.Duration
+			}
+			newEnd = offset + e - r.Start.Duration
+		}
+		offset += r.End.Duration - r.Start.Duration
 	}
-	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	return newStart, newEnd, newStart > -1
 }
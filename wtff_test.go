@@ -0,0 +1,98 @@
+package wtff
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeVideo gets a FakeRunner for a 60 second file with a video stream with
+// keyframes every 10 seconds, and a stereo AAC stream.
+func fakeVideo(video string) *FakeRunner {
+	return &FakeRunner{Output: func(prog string, args []string) (string, string, error) {
+		if prog == "ffprobe" && strings.Contains(strings.Join(args, " "), "-show_entries packet=") {
+			p := make([]string, 0, 6)
+			for i := range 6 {
+				p = append(p, `{"pts_time":"`+fmtSeconds(time.Duration(i)*10*time.Second)+`","flags":"K__"}`)
+			}
+			return `{"packets":[` + strings.Join(p, ",") + `]}`, "", nil
+		}
+		if prog == "ffprobe" {
+			return `{"format":{"duration":"60.000000"},"streams":[` + video +
+				`,{"index":1,"codec_name":"aac","codec_type":"audio","sample_rate":"48000","channels":2}]}`, "", nil
+		}
+		return fakeOutput(prog, args), "", nil
+	}}
+}
+
+func TestCutAccurate(t *testing.T) {
+	tests := []struct {
+		name  string
+		video string
+		want  []string
+		warn  string
+	}{
+		{"h264",
+			`{"index":0,"codec_name":"h264","codec_type":"video","profile":"High","pix_fmt":"yuv420p","level":40,"bit_rate":"2000000"}`,
+			[]string{
+				"-ss 00:05 -i in.mkv -to 00:05 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -bsf:0 dump_extra -c:0 libx264 -pix_fmt:0 yuv420p -profile:0 high -level:0 4.0 -b:0 2000000",
+				"-ss 00:10 -i in.mkv -to 00:10 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -bsf:0 dump_extra",
+				"-ss 00:20 -i in.mkv -to 00:05 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -bsf:0 dump_extra -c:0 libx264 -pix_fmt:0 yuv420p -profile:0 high -level:0 4.0 -b:0 2000000",
+			}, ""},
+		{"hevc",
+			`{"index":0,"codec_name":"hevc","codec_type":"video","profile":"Main 10","pix_fmt":"yuv420p10le","level":123}`,
+			[]string{
+				"-ss 00:05 -i in.mkv -to 00:05 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -bsf:0 dump_extra -c:0 libx265 -pix_fmt:0 yuv420p10le -profile:0 main10 -x265-params:0 level-idc=4.1",
+				"-ss 00:10 -i in.mkv -to 00:10 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -bsf:0 dump_extra",
+				"-ss 00:20 -i in.mkv -to 00:05 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -bsf:0 dump_extra -c:0 libx265 -pix_fmt:0 yuv420p10le -profile:0 main10 -x265-params:0 level-idc=4.1",
+			}, ""},
+		{"vp9",
+			`{"index":0,"codec_name":"vp9","codec_type":"video","profile":"Profile 0","pix_fmt":"yuv420p"}`,
+			[]string{
+				"-ss 00:05 -i in.mkv -to 00:05 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -c:0 libvpx-vp9 -pix_fmt:0 yuv420p",
+				"-ss 00:10 -i in.mkv -to 00:10 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy",
+				"-ss 00:20 -i in.mkv -to 00:05 -map 0 -map_chapters -1 -avoid_negative_ts make_zero -c copy -c:0 libvpx-vp9 -pix_fmt:0 yuv420p",
+			}, ""},
+		{"unknown profile",
+			`{"index":0,"codec_name":"h264","codec_type":"video","profile":"Extended","pix_fmt":"yuv420p"}`,
+			nil, `can't re-encode h264 with profile "Extended"`},
+		{"unknown codec",
+			`{"index":0,"codec_name":"mpeg2video","codec_type":"video","profile":"Main","pix_fmt":"yuv420p"}`,
+			nil, "can't re-encode mpeg2video video"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warn []string
+			ctx := WithWarning(WithRunner(context.Background(), fakeVideo(tt.video)), func(w string) { warn = append(warn, w) })
+			ctx, plan := DryRun(ctx)
+
+			err := CutAccurate(ctx, "in.mkv", "out.mkv", Time{Duration: 5 * time.Second}, Time{Duration: 20 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var parts []string
+			for _, c := range plan.Commands() {
+				if strings.HasPrefix(c, "ffmpeg ") && strings.Contains(c, " -ss ") {
+					c = strings.TrimPrefix(c, "ffmpeg -hide_banner -v level+warning -y ")
+					parts = append(parts, c[:strings.LastIndexByte(c, ' ')])
+				}
+			}
+			if tt.warn != "" {
+				if len(warn) == 0 || !strings.Contains(warn[0], tt.warn) {
+					t.Errorf("wrong warning\nhave: %q\nwant: %s", warn, tt.warn)
+				}
+				if len(parts) != 1 || strings.Contains(parts[0], "-c:0") {
+					t.Errorf("not cut on keyframes:\n%s", plan)
+				}
+				return
+			}
+			if !reflect.DeepEqual(parts, tt.want) {
+				t.Errorf("\nhave:\n%s\n\nwant:\n%s\n\nplan:\n%s", strings.Join(parts, "\n"), strings.Join(tt.want, "\n"), plan)
+			}
+		})
+	}
+}